@@ -0,0 +1,89 @@
+package main
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/INFURA/ipfs-pump/pump"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	filterCodec        = kingpin.Flag("filter-codec", "Filter: Only copy the CIDs with this codec (raw, dag-pb, dag-cbor ...), can be repeated").Strings()
+	filterExcludeCodec = kingpin.Flag("filter-exclude-codec", "Filter: Skip the CIDs with this codec, can be repeated").Strings()
+	filterMh           = kingpin.Flag("filter-mh", "Filter: Only copy the CIDs with this multihash type (sha2-256, blake2b-256 ...), can be repeated").Strings()
+	filterExcludeMh    = kingpin.Flag("filter-exclude-mh", "Filter: Skip the CIDs with this multihash type, can be repeated").Strings()
+	filterVersion      = kingpin.Flag("filter-version", "Filter: Only copy the CIDs with this version, can be repeated").Enums("0", "1")
+	filterSkipIdentity = kingpin.Flag("filter-skip-identity", "Filter: Skip the CIDs using the identity hash, which need no storage").Bool()
+	filterMinSize      = kingpin.Flag("filter-min-size", "Filter: Skip the blocks smaller than this size in bytes, after collection").Int()
+	filterMaxSize      = kingpin.Flag("filter-max-size", "Filter: Skip the blocks bigger than this size in bytes, after collection").Int()
+)
+
+// filterEnumerator wrap the enumerator with the CID filter, if any
+func filterEnumerator(enumerator pump.Enumerator) (pump.Enumerator, error) {
+	var filter pump.CIDFilter
+	var err error
+
+	filter.Codecs, err = parseCodes(*filterCodec, pump.ParseCodec)
+	if err != nil {
+		return nil, err
+	}
+	filter.ExcludeCodecs, err = parseCodes(*filterExcludeCodec, pump.ParseCodec)
+	if err != nil {
+		return nil, err
+	}
+	filter.MhTypes, err = parseCodes(*filterMh, pump.ParseMultihashType)
+	if err != nil {
+		return nil, err
+	}
+	filter.ExcludeMhTypes, err = parseCodes(*filterExcludeMh, pump.ParseMultihashType)
+	if err != nil {
+		return nil, err
+	}
+	filter.Versions, err = parseCodes(*filterVersion, func(s string) (uint64, error) {
+		return strconv.ParseUint(s, 10, 64)
+	})
+	if err != nil {
+		return nil, err
+	}
+	filter.SkipIdentity = *filterSkipIdentity
+
+	if len(filter.Codecs) == 0 && len(filter.ExcludeCodecs) == 0 &&
+		len(filter.MhTypes) == 0 && len(filter.ExcludeMhTypes) == 0 &&
+		len(filter.Versions) == 0 && !filter.SkipIdentity {
+		return enumerator, nil
+	}
+
+	return pump.NewFilterEnumerator(enumerator, filter), nil
+}
+
+// filterCollector wrap the collector with the size filter, if any
+func filterCollector(collector pump.Collector) pump.Collector {
+	if *filterMinSize <= 0 && *filterMaxSize <= 0 {
+		return collector
+	}
+
+	return pump.NewSizeFilterCollector(collector, pump.SizeFilter{Min: *filterMinSize, Max: *filterMaxSize})
+}
+
+// logFiltered log how many blocks were skipped by the filters
+func logFiltered(enumerator pump.Enumerator, collector pump.Collector) {
+	if filtered, ok := enumerator.(*pump.FilterEnumerator); ok {
+		log.Printf("filter: %d CIDs skipped", filtered.Skipped())
+	}
+	if filtered, ok := collector.(*pump.SizeFilterCollector); ok {
+		log.Printf("filter: %d blocks skipped by size", filtered.Skipped())
+	}
+}
+
+func parseCodes(names []string, parse func(string) (uint64, error)) ([]uint64, error) {
+	var codes []uint64
+	for _, name := range names {
+		code, err := parse(name)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 
@@ -11,39 +14,62 @@ import (
 )
 
 const (
-	EnumFile   = "file"
-	EnumAPIPin = "apipin"
-	EnumFlatFS = "flatfs"
-	EnumBadger = "badger"
-	EnumS3     = "s3"
+	EnumFile    = "file"
+	EnumAPIPin  = "apipin"
+	EnumAPIRefs = "apirefs"
+	EnumFlatFS  = "flatfs"
+	EnumBadger  = "badger"
+	EnumS3      = "s3"
+	EnumLevelDB = "leveldb"
+	EnumPebble  = "pebble"
+	EnumRepo    = "repo"
+	EnumPinSvc  = "pinsvc"
+	EnumCluster = "cluster"
+	EnumUnion   = "union"
 )
 
 const (
-	CollAPI    = "api"
-	CollFlatFS = "flatfs"
-	CollBadger = "badger"
-	CollS3     = "s3"
+	CollAPI      = "api"
+	CollFlatFS   = "flatfs"
+	CollBadger   = "badger"
+	CollS3       = "s3"
+	CollLevelDB  = "leveldb"
+	CollPebble   = "pebble"
+	CollRepo     = "repo"
+	CollGateway  = "gateway"
+	CollNone     = "none"
+	CollFallback = "fallback"
 )
 
 const (
-	DrainAPI    = "api"
-	DrainFlatFS = "flatfs"
-	DrainBadger = "badger"
-	DrainS3     = "s3"
-	DrainPin    = "pin"
+	DrainAPI       = "api"
+	DrainFlatFS    = "flatfs"
+	DrainBadger    = "badger"
+	DrainS3        = "s3"
+	DrainPin       = "pin"
+	DrainLevelDB   = "leveldb"
+	DrainPebble    = "pebble"
+	DrainRepo      = "repo"
+	DrainPinSvc    = "pinsvc"
+	DrainCluster   = "cluster"
+	DrainDagImport = "dagimport"
+	DrainTee       = "tee"
+	DrainDryRun    = "dryrun"
 )
 
 var (
-	enumValues = []string{EnumFile, EnumAPIPin, EnumFlatFS, EnumBadger, EnumS3}
-	enumArg    = kingpin.Arg("enum", "The source to enumerate the content. "+
+	pumpCmd = kingpin.Command("pump", "Copy blocks from a source to a destination.").Default()
+
+	enumValues = []string{EnumFile, EnumAPIPin, EnumAPIRefs, EnumFlatFS, EnumBadger, EnumS3, EnumLevelDB, EnumPebble, EnumRepo, EnumPinSvc, EnumCluster, EnumUnion}
+	enumArg    = pumpCmd.Arg("enum", "The source to enumerate the content. "+
 		"Possible values are ["+strings.Join(enumValues, ",")+"].").
 		Required().Enum(enumValues...)
-	collValues = []string{CollAPI, CollFlatFS, CollBadger, CollS3}
-	collArg    = kingpin.Arg("coll", "The source to get the data blocks. "+
+	collValues = []string{CollAPI, CollFlatFS, CollBadger, CollS3, CollLevelDB, CollPebble, CollRepo, CollGateway, CollNone, CollFallback}
+	collArg    = pumpCmd.Arg("coll", "The source to get the data blocks. "+
 		"Possible values are ["+strings.Join(collValues, ",")+"].").
 		Required().Enum(collValues...)
-	drainValues = []string{DrainAPI, DrainPin, DrainFlatFS, DrainBadger, DrainS3}
-	drainArg    = kingpin.Arg("drain", "The destination to copy to. "+
+	drainValues = []string{DrainAPI, DrainPin, DrainFlatFS, DrainBadger, DrainS3, DrainLevelDB, DrainPebble, DrainRepo, DrainPinSvc, DrainCluster, DrainDagImport, DrainTee, DrainDryRun}
+	drainArg    = pumpCmd.Arg("drain", "The destination to copy to. "+
 		"Possible values are ["+strings.Join(drainValues, ",")+"].").
 		Required().Enum(drainValues...)
 
@@ -52,13 +78,53 @@ var (
 
 	failedBlocksPath = kingpin.Flag("failed-blocks-path", "The path to a file where all the failed CIDs should be written").Default("").String()
 
-	enumFilePath    = kingpin.Flag("enum-file-path", "Enumerator "+EnumFile+": Path")
-	enumFilePathVal = enumFilePath.String()
+	apiHeader      = kingpin.Flag("api-header", "API roles: HTTP header to add to every request, as 'Name: value', can be repeated").Strings()
+	apiBasicAuth   = kingpin.Flag("api-basic-auth", "API roles: Basic auth credentials, as 'user:password'").String()
+	apiBearerToken = kingpin.Flag("api-bearer-token", "API roles: Bearer token").String()
+	apiTLSCA       = kingpin.Flag("api-tls-ca", "API roles: PEM file of CA certificates to trust").String()
+	apiTLSCert     = kingpin.Flag("api-tls-cert", "API roles: PEM client certificate").String()
+	apiTLSKey      = kingpin.Flag("api-tls-key", "API roles: PEM client key").String()
+	apiTimeout     = kingpin.Flag("api-timeout", "API roles: Timeout to connect and receive the response headers").Default("0s").Duration()
+
+	enumUnion        = kingpin.Flag("enum-union", "Enumerator "+EnumUnion+": Source enumerator, can be repeated. Either a type configured with its own flags, or 'type:path' for the file and datastore types")
+	enumUnionVal     = enumUnion.Strings()
+	enumUnionDisk    = kingpin.Flag("enum-union-disk", "Enumerator "+EnumUnion+": Remove the duplicates using a temporary on-disk database instead of memory, for very large inputs")
+	enumUnionDiskVal = enumUnionDisk.Bool()
+
+	enumFilePath         = kingpin.Flag("enum-file-path", "Enumerator "+EnumFile+": Path, or '-' for stdin. Lists compressed with gzip or zstd are decompressed")
+	enumFilePathVal      = enumFilePath.String()
+	enumFileSkipCount    = kingpin.Flag("enum-file-skip-count", "Enumerator "+EnumFile+": Don't read the list a first time to count the entries, always skipped for stdin")
+	enumFileSkipCountVal = enumFileSkipCount.Bool()
+	enumFileFormat       = kingpin.Flag("enum-file-format", "Enumerator "+EnumFile+": Format of the list: one CID per line, JSON objects as output by the IPFS commands with --enc=json, or CSV").Default(pump.FileFormatLines)
+	enumFileFormatVal    = enumFileFormat.Enum(pump.FileFormatLines, pump.FileFormatJSON, pump.FileFormatCSV)
+	enumFileCSVColumn    = kingpin.Flag("enum-file-csv-column", "Enumerator "+EnumFile+": CSV column holding the CID, either its 1-based index or its name in the header")
+	enumFileCSVColumnVal = enumFileCSVColumn.Default("1").String()
+	enumFileCSVHeader    = kingpin.Flag("enum-file-csv-header", "Enumerator "+EnumFile+": The first CSV row name the columns, the other columns are kept as metadata")
+	enumFileCSVHeaderVal = enumFileCSVHeader.Bool()
 
 	enumAPIPinURL       = kingpin.Flag("enum-api-pin-url", "Enumerator "+EnumAPIPin+": API URL")
 	enumAPIPinURLVal    = enumAPIPinURL.String()
 	enumAPIPinStream    = kingpin.Flag("enum-api-pin-stream", "Enumerator "+EnumAPIPin+": Stream")
 	enumAPIPinStreamVal = enumAPIPinStream.Bool()
+	enumAPIPinType      = kingpin.Flag("enum-api-pin-type", "Enumerator "+EnumAPIPin+": Pin type to enumerate, can be repeated (default: all)")
+	enumAPIPinTypeVal   = enumAPIPinType.Enums(pump.PinTypeRecursive, pump.PinTypeDirect, pump.PinTypeIndirect)
+	enumAPIPinNames     = kingpin.Flag("enum-api-pin-names", "Enumerator "+EnumAPIPin+": Also retrieve the pin names (Kubo >= 0.13)")
+	enumAPIPinNamesVal  = enumAPIPinNames.Bool()
+
+	enumPinSvcURL       = kingpin.Flag("enum-pinsvc-url", "Enumerator "+EnumPinSvc+": Pinning Service API endpoint")
+	enumPinSvcURLVal    = enumPinSvcURL.String()
+	enumPinSvcToken     = kingpin.Flag("enum-pinsvc-token", "Enumerator "+EnumPinSvc+": Access token")
+	enumPinSvcTokenVal  = enumPinSvcToken.String()
+	enumPinSvcStatus    = kingpin.Flag("enum-pinsvc-status", "Enumerator "+EnumPinSvc+": Pin status to enumerate, can be repeated").Default("pinned")
+	enumPinSvcStatusVal = enumPinSvcStatus.Enums("queued", "pinning", "pinned", "failed")
+	enumPinSvcName      = kingpin.Flag("enum-pinsvc-name", "Enumerator "+EnumPinSvc+": Only enumerate the pins with this name")
+	enumPinSvcNameVal   = enumPinSvcName.String()
+
+	enumClusterURL    = kingpin.Flag("enum-cluster-url", "Enumerator "+EnumCluster+": Cluster REST API URL")
+	enumClusterURLVal = enumClusterURL.String()
+
+	enumAPIRefsURL    = kingpin.Flag("enum-api-refs-url", "Enumerator "+EnumAPIRefs+": API URL")
+	enumAPIRefsURLVal = enumAPIRefsURL.String()
 
 	enumFlatFSPath    = kingpin.Flag("enum-flatfs-path", "Enumerator "+EnumFlatFS+": Path")
 	enumFlatFSPathVal = enumFlatFSPath.String()
@@ -66,6 +132,15 @@ var (
 	enumBadgerPath    = kingpin.Flag("enum-badger-path", "Enumerator "+EnumBadger+": Path")
 	enumBadgerPathVal = enumBadgerPath.String()
 
+	enumLevelDBPath    = kingpin.Flag("enum-leveldb-path", "Enumerator "+EnumLevelDB+": Path")
+	enumLevelDBPathVal = enumLevelDBPath.String()
+
+	enumPebblePath    = kingpin.Flag("enum-pebble-path", "Enumerator "+EnumPebble+": Path")
+	enumPebblePathVal = enumPebblePath.String()
+
+	enumRepoPath    = kingpin.Flag("enum-repo-path", "Enumerator "+EnumRepo+": Path of the IPFS repo")
+	enumRepoPathVal = enumRepoPath.String()
+
 	enumS3Region          = kingpin.Flag("enum-s3-region", "Enumerator "+EnumS3+": Region")
 	enumS3RegionVal       = enumS3Region.String()
 	enumS3Bucket          = kingpin.Flag("enum-s3-bucket", "Enumerator "+EnumS3+": Bucket name")
@@ -80,12 +155,31 @@ var (
 	collAPIURL    = kingpin.Flag("coll-api-url", "Collector "+CollAPI+": API URL")
 	collAPIURLVal = collAPIURL.String()
 
+	collFallback    = kingpin.Flag("coll-fallback", "Collector "+CollFallback+": Source collector, configured with its own flags, can be repeated. Sources are tried in order")
+	collFallbackVal = collFallback.Enums(collValues...)
+
+	collGatewayURL         = kingpin.Flag("coll-gateway-url", "Collector "+CollGateway+": Gateway URL, can be repeated for failover")
+	collGatewayURLVal      = collGatewayURL.Strings()
+	collGatewayTimeout     = kingpin.Flag("coll-gateway-timeout", "Collector "+CollGateway+": Timeout of each request").Default("30s")
+	collGatewayTimeoutVal  = collGatewayTimeout.Duration()
+	collGatewayParallel    = kingpin.Flag("coll-gateway-parallel", "Collector "+CollGateway+": Number of parallel requests per worker").Default("1")
+	collGatewayParallelVal = collGatewayParallel.Uint()
+
 	collFlatFSPath    = kingpin.Flag("coll-flatfs-path", "Collector "+CollFlatFS+": Path")
 	collFlatFSPathVal = collFlatFSPath.String()
 
 	collBadgerPath    = kingpin.Flag("coll-badger-path", "Collector "+CollBadger+": Path")
 	collBadgerPathVal = collBadgerPath.String()
 
+	collLevelDBPath    = kingpin.Flag("coll-leveldb-path", "Collector "+CollLevelDB+": Path")
+	collLevelDBPathVal = collLevelDBPath.String()
+
+	collPebblePath    = kingpin.Flag("coll-pebble-path", "Collector "+CollPebble+": Path")
+	collPebblePathVal = collPebblePath.String()
+
+	collRepoPath    = kingpin.Flag("coll-repo-path", "Collector "+CollRepo+": Path of the IPFS repo")
+	collRepoPathVal = collRepoPath.String()
+
 	collS3Region          = kingpin.Flag("coll-s3-region", "Collector "+EnumS3+": Region")
 	collS3RegionVal       = collS3Region.String()
 	collS3Bucket          = kingpin.Flag("coll-s3-bucket", "Collector "+CollS3+": Bucket name")
@@ -100,15 +194,49 @@ var (
 	drainAPIURL    = kingpin.Flag("drain-api-url", "Drain "+DrainAPI+": API URL")
 	drainAPIURLVal = drainAPIURL.String()
 
+	drainDryRunLargest    = kingpin.Flag("drain-dryrun-largest", "Drain "+DrainDryRun+": Number of largest blocks to report").Default("10")
+	drainDryRunLargestVal = drainDryRunLargest.Int()
+	drainDryRunReport     = kingpin.Flag("drain-dryrun-report", "Drain "+DrainDryRun+": Path of the inventory report (default: stdout)")
+	drainDryRunReportVal  = drainDryRunReport.String()
+
+	drainTee          = kingpin.Flag("drain-tee", "Drain "+DrainTee+": Destination drain, configured with its own flags, can be repeated")
+	drainTeeVal       = drainTee.Enums(drainValues...)
+	drainTeePolicy    = kingpin.Flag("drain-tee-policy", "Drain "+DrainTee+": Report a block as failed if any destination failed, or only if all failed").Default(pump.TeeFailAny)
+	drainTeePolicyVal = drainTeePolicy.Enum(pump.TeeFailAny, pump.TeeFailAll)
+
+	drainDagImportURL          = kingpin.Flag("drain-dagimport-url", "Drain "+DrainDagImport+": API URL")
+	drainDagImportURLVal       = drainDagImportURL.String()
+	drainDagImportChunkSize    = kingpin.Flag("drain-dagimport-chunk-size", "Drain "+DrainDagImport+": Approximate size in bytes of each imported CAR chunk").Default("16777216")
+	drainDagImportChunkSizeVal = drainDagImportChunkSize.Int()
+
+	drainPinSvcURL      = kingpin.Flag("drain-pinsvc-url", "Drain "+DrainPinSvc+": Pinning Service API endpoint")
+	drainPinSvcURLVal   = drainPinSvcURL.String()
+	drainPinSvcToken    = kingpin.Flag("drain-pinsvc-token", "Drain "+DrainPinSvc+": Access token")
+	drainPinSvcTokenVal = drainPinSvcToken.String()
+
+	drainClusterURL                = kingpin.Flag("drain-cluster-url", "Drain "+DrainCluster+": Cluster REST API URL")
+	drainClusterURLVal             = drainClusterURL.String()
+	drainClusterKeepAllocations    = kingpin.Flag("drain-cluster-keep-allocations", "Drain "+DrainCluster+": Re-use the source allocations, if both clusters share the same peers")
+	drainClusterKeepAllocationsVal = drainClusterKeepAllocations.Bool()
+
 	drainFlatFSPath    = kingpin.Flag("drain-flatfs-path", "Drain "+DrainFlatFS+": Path")
 	drainFlatFSPathVal = drainFlatFSPath.String()
 
 	drainBadgerPath    = kingpin.Flag("drain-badger-path", "Drain "+DrainBadger+": Path")
 	drainBadgerPathVal = drainBadgerPath.String()
 
+	drainLevelDBPath    = kingpin.Flag("drain-leveldb-path", "Drain "+DrainLevelDB+": Path")
+	drainLevelDBPathVal = drainLevelDBPath.String()
+
+	drainPebblePath    = kingpin.Flag("drain-pebble-path", "Drain "+DrainPebble+": Path")
+	drainPebblePathVal = drainPebblePath.String()
+
+	drainRepoPath    = kingpin.Flag("drain-repo-path", "Drain "+DrainRepo+": Path of the IPFS repo")
+	drainRepoPathVal = drainRepoPath.String()
+
 	drainPinAPIURL      = kingpin.Flag("drain-pin-url", "Drain "+DrainPin+": API URL")
 	drainPinAPIURLVal   = drainPinAPIURL.String()
-	drainCheckAPIURL    = kingpin.Flag("drain-check-url", "Drain "+DrainPin+": API URL")
+	drainCheckAPIURL    = kingpin.Flag("drain-check-url", "Drain "+DrainPin+": API URL of the source node, to check the pin type when the enumerator doesn't provide it")
 	drainCheckAPIURLVal = drainCheckAPIURL.String()
 
 	drainS3Region          = kingpin.Flag("drain-s3-region", "Drain "+EnumS3+": Region")
@@ -124,31 +252,303 @@ var (
 )
 
 func main() {
-	kingpin.Parse()
+	cmd := kingpin.Parse()
+	setupShard()
+
+	switch cmd {
+	case pumpCmd.FullCommand():
+		runPump()
+	case rawCmd.FullCommand():
+		runRaw()
+	case verifyCmd.FullCommand():
+		if !runVerify() {
+			os.Exit(1)
+		}
+	}
+}
+
+func runPump() {
+	enumerator, err := buildEnumerator(*enumArg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	var enumerator pump.Enumerator
-	var collector pump.Collector
-	var drain pump.Drain
-	var err error
+	collector, err := buildCollector(*collArg)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	switch *enumArg {
-	case EnumFile:
-		requiredFlag(enumFilePath, *enumFilePathVal)
-		var file *os.File
-		file, err = os.Open(*enumFilePathVal)
+	drain, err := buildDrain(*drainArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	defer closeAll()
+
+	progressWriter := pump.NewProgressWriter()
+
+	var failedBlocksWriter pump.FailedBlocksWriter
+	if *failedBlocksPath == "" {
+		failedBlocksWriter = pump.NewNullableFileEnumeratorWriter()
+	} else {
+		enumWriter, closeWriter, err := pump.NewFileEnumeratorWriter(*failedBlocksPath)
 		if err != nil {
 			log.Fatal(err)
 		}
-		enumerator, err = pump.NewFileEnumerator(file)
+		failedBlocksWriter = enumWriter
+
+		defer func() {
+			err = closeWriter()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	shardedEnumerator, err := shardEnumerator(enumerator)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filteredEnumerator, err := filterEnumerator(shardedEnumerator)
+	if err != nil {
+		log.Fatal(err)
+	}
+	filteredCollector := filterCollector(collector)
+
+	limitedDrain, err := limitDrain(drain)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	transcodedDrain, err := transcodeDrain(limitedDrain)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	pump.PumpIt(filteredEnumerator, filteredCollector, transcodedDrain, failedBlocksWriter, progressWriter, *worker)
+
+	logFiltered(filteredEnumerator, filteredCollector)
+	logOversized(limitedDrain)
+
+	if dryRun, ok := drain.(*pump.DryRunDrain); ok {
+		err = writeReport(*drainDryRunReportVal, dryRun.Inventory().WriteReport)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("%s: %d blocks failed to be enumerated or collected", DrainDryRun, failedBlocksWriter.Count())
+	}
+
+	if union, ok := enumerator.(*pump.UnionEnumerator); ok {
+		log.Printf("%s: %d duplicated blocks skipped", EnumUnion, union.Duplicates())
+	}
+
+	if fallback, ok := collector.(*pump.FallbackCollector); ok {
+		stats, missed := fallback.Stats()
+		for _, stat := range stats {
+			log.Printf("%s: %d blocks served by %s", CollFallback, stat.Served, stat.Name)
+		}
+		log.Printf("%s: %d blocks missing from all sources", CollFallback, missed)
+	}
+}
+
+// writeReport write a report to the given path, or to stdout if empty
+func writeReport(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = write(file)
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// closers are called once the pump is done
+var closers []func() error
+
+// closeAll call the closers, once the command is done
+func closeAll() {
+	for _, closeFn := range closers {
+		err := closeFn()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// closeOnExit close the datastore of a drain once the pump is done, so that
+// the last writes are persisted
+func closeOnExit(drain *pump.DatastoreDrain, err error) (pump.Drain, error) {
+	if err != nil {
+		return nil, err
+	}
+	closers = append(closers, drain.Datastore().Close)
+	return drain, nil
+}
+
+// buildTeeDrain build each destination of the tee drain. When the failed blocks are
+// written to a file, the failures of each destination go to <path>.<drain>.
+func buildTeeDrain(kinds []string) (pump.Drain, error) {
+	var destinations []pump.TeeDestination
+	seen := make(map[string]bool)
+
+	for _, kind := range kinds {
+		if kind == DrainTee {
+			return nil, fmt.Errorf("%s can't be a destination of %s", DrainTee, DrainTee)
+		}
+		if seen[kind] {
+			return nil, fmt.Errorf("drain %s is used twice by %s, but share the same flags", kind, DrainTee)
+		}
+		seen[kind] = true
+
+		drain, err := buildDrain(kind)
+		if err != nil {
+			return nil, err
+		}
+
+		dest := pump.TeeDestination{Name: kind, Drain: drain}
+
+		if *failedBlocksPath != "" {
+			writer, closeWriter, err := pump.NewFileEnumeratorWriter(*failedBlocksPath + "." + kind)
+			if err != nil {
+				return nil, err
+			}
+			dest.Failed = writer
+			closers = append(closers, closeWriter)
+		}
+
+		destinations = append(destinations, dest)
+	}
+
+	return pump.NewTeeDrain(*drainTeePolicyVal, destinations...)
+}
+
+// buildFallbackCollector build each source of the fallback collector, in order
+func buildFallbackCollector(kinds []string) (pump.Collector, error) {
+	var sources []*pump.FallbackSource
+	seen := make(map[string]bool)
+
+	for _, kind := range kinds {
+		if kind == CollFallback {
+			return nil, fmt.Errorf("%s can't be a source of %s", CollFallback, CollFallback)
+		}
+		if seen[kind] {
+			return nil, fmt.Errorf("collector %s is used twice by %s, but share the same flags", kind, CollFallback)
+		}
+		seen[kind] = true
+
+		collector, err := buildCollector(kind)
+		if err != nil {
+			return nil, err
+		}
+
+		sources = append(sources, &pump.FallbackSource{Name: kind, Collector: collector})
+	}
+
+	return pump.NewFallbackCollector(sources...)
+}
+
+// buildUnionEnumerator build each source of the union enumerator. A source
+// is either an enumerator type, or type:path to override the path flag so
+// that the same type can be used several times.
+func buildUnionEnumerator(specs []string) (pump.Enumerator, error) {
+	pathFlags := map[string]*string{
+		EnumFile:    enumFilePathVal,
+		EnumFlatFS:  enumFlatFSPathVal,
+		EnumBadger:  enumBadgerPathVal,
+		EnumLevelDB: enumLevelDBPathVal,
+		EnumPebble:  enumPebblePathVal,
+		EnumRepo:    enumRepoPathVal,
+	}
+
+	var enumerators []pump.Enumerator
+	for _, spec := range specs {
+		kind, path := spec, ""
+		if i := strings.Index(spec, ":"); i >= 0 {
+			kind, path = spec[:i], spec[i+1:]
+		}
+
+		if kind == EnumUnion {
+			return nil, fmt.Errorf("%s can't be a source of %s", EnumUnion, EnumUnion)
+		}
+
+		if path != "" {
+			pathFlag, ok := pathFlags[kind]
+			if !ok {
+				return nil, fmt.Errorf("enumerator %s doesn't take a path", kind)
+			}
+			*pathFlag = path
+		}
+
+		enumerator, err := buildEnumerator(kind)
+		if err != nil {
+			return nil, err
+		}
+		enumerators = append(enumerators, enumerator)
+	}
+
+	seen := pump.NewMemoryCIDSet()
+	if *enumUnionDiskVal {
+		var err error
+		seen, err = pump.NewDiskCIDSet()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return pump.NewUnionEnumerator(seen, enumerators...), nil
+}
+
+func buildEnumerator(kind string) (pump.Enumerator, error) {
+	switch kind {
+	case EnumUnion:
+		requiredFlag(enumUnion, strings.Join(*enumUnionVal, ","))
+		return buildUnionEnumerator(*enumUnionVal)
+	case EnumFile:
+		requiredFlag(enumFilePath, *enumFilePathVal)
+		return pump.OpenFileEnumerator(*enumFilePathVal, !*enumFileSkipCountVal, pump.FileOptions{
+			Format:    *enumFileFormatVal,
+			CSVColumn: *enumFileCSVColumnVal,
+			CSVHeader: *enumFileCSVHeaderVal,
+		})
 	case EnumAPIPin:
 		requiredFlag(enumAPIPinURL, *enumAPIPinURLVal)
-		enumerator = pump.NewAPIPinEnumerator(*enumAPIPinURLVal, *enumAPIPinStreamVal)
+		enumerator := pump.NewAPIPinEnumeratorWithClient(*enumAPIPinURLVal, *enumAPIPinStreamVal, apiClient())
+		enumerator.Types = *enumAPIPinTypeVal
+		enumerator.Names = *enumAPIPinNamesVal
+		return enumerator, nil
+	case EnumPinSvc:
+		requiredFlag(enumPinSvcURL, *enumPinSvcURLVal)
+		return pump.NewPinningServiceEnumerator(*enumPinSvcURLVal, *enumPinSvcTokenVal, *enumPinSvcStatusVal, *enumPinSvcNameVal, apiClient()), nil
+	case EnumCluster:
+		requiredFlag(enumClusterURL, *enumClusterURLVal)
+		return pump.NewClusterEnumerator(*enumClusterURLVal, apiClient()), nil
+	case EnumAPIRefs:
+		requiredFlag(enumAPIRefsURL, *enumAPIRefsURLVal)
+		return pump.NewAPIRefsEnumeratorWithClient(*enumAPIRefsURLVal, apiClient()), nil
 	case EnumFlatFS:
 		requiredFlag(enumFlatFSPath, *enumFlatFSPathVal)
-		enumerator, err = pump.NewFlatFSEnumerator(*enumFlatFSPathVal)
+		return pump.NewFlatFSEnumerator(*enumFlatFSPathVal)
 	case EnumBadger:
 		requiredFlag(enumBadgerPath, *enumBadgerPathVal)
-		enumerator, err = pump.NewBadgerEnumerator(*enumBadgerPathVal)
+		return pump.NewBadgerEnumerator(*enumBadgerPathVal)
+	case EnumLevelDB:
+		requiredFlag(enumLevelDBPath, *enumLevelDBPathVal)
+		return pump.NewLevelDBEnumerator(*enumLevelDBPathVal)
+	case EnumPebble:
+		requiredFlag(enumPebblePath, *enumPebblePathVal)
+		return pump.NewPebbleEnumerator(*enumPebblePathVal)
+	case EnumRepo:
+		requiredFlag(enumRepoPath, *enumRepoPathVal)
+		return pump.NewRepoEnumerator(*enumRepoPathVal)
 	case EnumS3:
 		requiredFlag(enumS3Region, *enumS3RegionVal)
 		requiredFlag(enumS3Bucket, *enumS3BucketVal)
@@ -161,23 +561,39 @@ func main() {
 			SessionToken: *enumS3SessionTokenVal,
 		}
 
-		enumerator, err = pump.NewS3Enumerator(config)
-	}
-
-	if err != nil {
-		log.Fatal(err)
+		return pump.NewS3Enumerator(config)
 	}
+	return nil, fmt.Errorf("unknown enumerator %s", kind)
+}
 
-	switch *collArg {
+func buildCollector(kind string) (pump.Collector, error) {
+	switch kind {
 	case CollAPI:
 		requiredFlag(collAPIURL, *collAPIURLVal)
-		collector = pump.NewAPICollector(*collAPIURLVal)
+		return pump.NewAPICollectorWithClient(*collAPIURLVal, apiClient()), nil
+	case CollGateway:
+		requiredFlag(collGatewayURL, strings.Join(*collGatewayURLVal, ""))
+		return pump.NewGatewayCollector(*collGatewayURLVal, *collGatewayTimeoutVal, *collGatewayParallelVal), nil
+	case CollFallback:
+		requiredFlag(collFallback, strings.Join(*collFallbackVal, ","))
+		return buildFallbackCollector(*collFallbackVal)
+	case CollNone:
+		return pump.NewNoopCollector(), nil
 	case CollFlatFS:
 		requiredFlag(collFlatFSPath, *collFlatFSPathVal)
-		collector, err = pump.NewFlatFSCollector(*collFlatFSPathVal)
+		return pump.NewFlatFSCollector(*collFlatFSPathVal)
 	case CollBadger:
 		requiredFlag(collBadgerPath, *collBadgerPathVal)
-		collector, err = pump.NewBadgerCollector(*collBadgerPathVal)
+		return pump.NewBadgerCollector(*collBadgerPathVal)
+	case CollLevelDB:
+		requiredFlag(collLevelDBPath, *collLevelDBPathVal)
+		return pump.NewLevelDBCollector(*collLevelDBPathVal)
+	case CollPebble:
+		requiredFlag(collPebblePath, *collPebblePathVal)
+		return pump.NewPebbleCollector(*collPebblePathVal)
+	case CollRepo:
+		requiredFlag(collRepoPath, *collRepoPathVal)
+		return pump.NewRepoCollector(*collRepoPathVal)
 	case CollS3:
 		requiredFlag(collS3Region, *collS3RegionVal)
 		requiredFlag(collS3Bucket, *collS3BucketVal)
@@ -190,27 +606,48 @@ func main() {
 			SessionToken: *collS3SessionTokenVal,
 		}
 
-		collector, err = pump.NewS3Collector(config)
-	}
-
-	if err != nil {
-		log.Fatal(err)
+		return pump.NewS3Collector(config)
 	}
+	return nil, fmt.Errorf("unknown collector %s", kind)
+}
 
-	switch *drainArg {
+func buildDrain(kind string) (pump.Drain, error) {
+	switch kind {
 	case DrainAPI:
 		requiredFlag(drainAPIURL, *drainAPIURLVal)
-		drain = pump.NewAPIDrain(*drainAPIURLVal)
+		return pump.NewAPIDrainWithClient(*drainAPIURLVal, apiClient()), nil
 	case DrainPin:
 		requiredFlag(drainPinAPIURL, *drainPinAPIURLVal)
-		requiredFlag(drainCheckAPIURL, *drainCheckAPIURLVal)
-		drain, err = pump.NewPinDrain(*drainPinAPIURLVal, *drainCheckAPIURLVal)
+		return pump.NewPinDrainWithClient(*drainPinAPIURLVal, *drainCheckAPIURLVal, apiClient())
+	case DrainDryRun:
+		return pump.NewDryRunDrain(*drainDryRunLargestVal), nil
+	case DrainTee:
+		requiredFlag(drainTee, strings.Join(*drainTeeVal, ","))
+		return buildTeeDrain(*drainTeeVal)
+	case DrainDagImport:
+		requiredFlag(drainDagImportURL, *drainDagImportURLVal)
+		return pump.NewDagImportDrain(*drainDagImportURLVal, apiClient(), *drainDagImportChunkSizeVal), nil
+	case DrainPinSvc:
+		requiredFlag(drainPinSvcURL, *drainPinSvcURLVal)
+		return pump.NewPinningServiceDrain(*drainPinSvcURLVal, *drainPinSvcTokenVal, apiClient()), nil
+	case DrainCluster:
+		requiredFlag(drainClusterURL, *drainClusterURLVal)
+		return pump.NewClusterDrain(*drainClusterURLVal, apiClient(), *drainClusterKeepAllocationsVal), nil
 	case DrainFlatFS:
 		requiredFlag(drainFlatFSPath, *drainFlatFSPathVal)
-		drain, err = pump.NewFlatFSDrain(*drainFlatFSPathVal)
+		return pump.NewFlatFSDrain(*drainFlatFSPathVal)
 	case DrainBadger:
 		requiredFlag(drainBadgerPath, *drainBadgerPathVal)
-		drain, err = pump.NewBadgerDrain(*drainBadgerPathVal)
+		return pump.NewBadgerDrain(*drainBadgerPathVal)
+	case DrainLevelDB:
+		requiredFlag(drainLevelDBPath, *drainLevelDBPathVal)
+		return closeOnExit(pump.NewLevelDBDrain(*drainLevelDBPathVal))
+	case DrainPebble:
+		requiredFlag(drainPebblePath, *drainPebblePathVal)
+		return closeOnExit(pump.NewPebbleDrain(*drainPebblePathVal))
+	case DrainRepo:
+		requiredFlag(drainRepoPath, *drainRepoPathVal)
+		return closeOnExit(pump.NewRepoDrain(*drainRepoPathVal))
 	case DrainS3:
 		requiredFlag(drainS3Region, *drainS3RegionVal)
 		requiredFlag(drainS3Bucket, *drainS3BucketVal)
@@ -223,34 +660,45 @@ func main() {
 			SessionToken: *drainS3SessionTokenVal,
 		}
 
-		drain, err = pump.NewS3Drain(config)
+		return pump.NewS3Drain(config)
 	}
+	return nil, fmt.Errorf("unknown drain %s", kind)
+}
 
-	if err != nil {
-		log.Fatal(err)
+// apiClient build the HTTP client shared by all the API roles
+func apiClient() *http.Client {
+	config := pump.APIClientConfig{
+		Headers:       http.Header{},
+		BearerToken:   *apiBearerToken,
+		TLSCACertPath: *apiTLSCA,
+		TLSCertPath:   *apiTLSCert,
+		TLSKeyPath:    *apiTLSKey,
+		Timeout:       *apiTimeout,
 	}
 
-	progressWriter := pump.NewProgressWriter()
+	for _, header := range *apiHeader {
+		split := strings.SplitN(header, ":", 2)
+		if len(split) != 2 {
+			log.Fatalf("invalid header %q, expected 'Name: value'", header)
+		}
+		config.Headers.Add(strings.TrimSpace(split[0]), strings.TrimSpace(split[1]))
+	}
 
-	var failedBlocksWriter pump.FailedBlocksWriter
-	if *failedBlocksPath == "" {
-		failedBlocksWriter = pump.NewNullableFileEnumeratorWriter()
-	} else {
-		enumWriter, closeWriter, err := pump.NewFileEnumeratorWriter(*failedBlocksPath)
-		if err != nil {
-			log.Fatal(err)
+	if *apiBasicAuth != "" {
+		split := strings.SplitN(*apiBasicAuth, ":", 2)
+		if len(split) != 2 {
+			log.Fatal("invalid basic auth, expected 'user:password'")
 		}
-		failedBlocksWriter = enumWriter
+		config.BasicAuthUser = split[0]
+		config.BasicAuthPassword = split[1]
+	}
 
-		defer func() {
-			err = closeWriter()
-			if err != nil {
-				log.Fatal(err)
-			}
-		}()
+	client, err := config.NewHTTPClient()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	pump.PumpIt(enumerator, collector, drain, failedBlocksWriter, progressWriter, *worker)
+	return client
 }
 
 func requiredFlag(flag *kingpin.FlagClause, val string) {
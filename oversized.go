@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/INFURA/ipfs-pump/pump"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	drainMaxBlockSize    = kingpin.Flag("drain-max-block-size", "Keep the blocks bigger than this size in bytes away from the drain, as some destinations refuse them").Int()
+	drainOversized       = kingpin.Flag("drain-oversized", "Drain the blocks above --drain-max-block-size to this other drain, configured with its own flags").Enum(drainValues...)
+	drainOversizedReport = kingpin.Flag("drain-oversized-report", "Write the CIDs and sizes of the blocks above --drain-max-block-size to this file instead of failing them").String()
+)
+
+// limitDrain wrap the drain with the block size limit, if any
+func limitDrain(drain pump.Drain) (pump.Drain, error) {
+	if *drainMaxBlockSize <= 0 {
+		if *drainOversized != "" || *drainOversizedReport != "" {
+			return nil, fmt.Errorf("--drain-oversized and --drain-oversized-report require --drain-max-block-size")
+		}
+		return drain, nil
+	}
+
+	var secondary pump.Drain
+	if *drainOversized != "" {
+		if *drainOversized == *drainArg || *drainOversized == DrainTee {
+			return nil, fmt.Errorf("drain %s can't receive the oversized blocks of %s", *drainOversized, *drainArg)
+		}
+
+		var err error
+		secondary, err = buildDrain(*drainOversized)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var report io.Writer
+	if *drainOversizedReport != "" {
+		file, err := os.Create(shardPath(*drainOversizedReport))
+		if err != nil {
+			return nil, err
+		}
+		closers = append(closers, file.Close)
+		report = file
+	}
+
+	return pump.NewSizeLimitDrain(drain, *drainMaxBlockSize, secondary, report)
+}
+
+// logOversized log how many blocks were above the size limit
+func logOversized(drain pump.Drain) {
+	if limited, ok := drain.(*pump.SizeLimitDrain); ok {
+		log.Printf("%d blocks above the size limit of %d bytes", limited.Oversized(), *drainMaxBlockSize)
+	}
+}
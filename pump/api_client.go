@@ -0,0 +1,148 @@
+package pump
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	shell "github.com/ipfs/go-ipfs-api"
+	client "github.com/ipfs/go-ipfs-http-client"
+	"github.com/multiformats/go-multiaddr"
+	"github.com/pkg/errors"
+)
+
+// APIClientConfig describe how to reach a Kubo RPC API (or IPFS Cluster proxy)
+// sitting behind authentication and/or TLS. The same config is used for every
+// API role.
+type APIClientConfig struct {
+	// Headers are added to every request
+	Headers http.Header
+
+	BasicAuthUser     string
+	BasicAuthPassword string
+	BearerToken       string
+
+	// TLSCACertPath is a PEM file of CA certificates to trust, in addition
+	// to the system ones
+	TLSCACertPath string
+	// TLSCertPath and TLSKeyPath are a PEM client certificate and key
+	TLSCertPath string
+	TLSKeyPath  string
+
+	// Timeout limit the time to connect and receive the response headers.
+	// It doesn't apply to reading the body, as responses can be long streams.
+	Timeout time.Duration
+}
+
+// NewHTTPClient build an HTTP client applying the config to every request
+func (c APIClientConfig) NewHTTPClient() (*http.Client, error) {
+	tlsConfig, err := c.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{Timeout: c.Timeout}
+
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   c.Timeout,
+		ResponseHeaderTimeout: c.Timeout,
+	}
+
+	return &http.Client{
+		Transport: &authTransport{config: c, next: transport},
+	}, nil
+}
+
+func (c APIClientConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+
+	if c.TLSCACertPath != "" {
+		pem, err := ioutil.ReadFile(c.TLSCACertPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the CA certificates")
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no CA certificate found in %s", c.TLSCACertPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if c.TLSCertPath != "" || c.TLSKeyPath != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLSCertPath, c.TLSKeyPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load the client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// authTransport add the configured headers and credentials to each request
+type authTransport struct {
+	config APIClientConfig
+	next   http.RoundTripper
+}
+
+func (a *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the original request
+	req = req.Clone(req.Context())
+
+	for name, values := range a.config.Headers {
+		req.Header.Del(name)
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+
+	// the credentials of the role itself (e.g. a Pinning Service token) win
+	switch {
+	case req.Header.Get("Authorization") != "":
+	case a.config.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+a.config.BearerToken)
+	case a.config.BasicAuthUser != "":
+		req.SetBasicAuth(a.config.BasicAuthUser, a.config.BasicAuthPassword)
+	}
+
+	return a.next.RoundTrip(req)
+}
+
+// newHTTPApi create a CoreAPI client from either a multiaddr or an URL,
+// the latter allowing to use HTTPS.
+func newHTTPApi(addr string, c *http.Client) (*client.HttpApi, error) {
+	if c == nil {
+		c = &http.Client{
+			Transport: &http.Transport{
+				Proxy:             http.ProxyFromEnvironment,
+				DisableKeepAlives: true,
+			},
+		}
+	}
+
+	ma, err := multiaddr.NewMultiaddr(addr)
+	if err == nil {
+		return client.NewApiWithClient(ma, c)
+	}
+
+	return client.NewURLApiWithClient(addr, c)
+}
+
+// newShell create a go-ipfs-api shell, using the given HTTP client if any
+func newShell(URL string, c *http.Client) *shell.Shell {
+	if c == nil {
+		return shell.NewShell(URL)
+	}
+	return shell.NewShellWithClient(URL, c)
+}
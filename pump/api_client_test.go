@@ -0,0 +1,48 @@
+package pump
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIClientConfig(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		_, _ = w.Write([]byte(`{"Version": "0.0.0"}`))
+	}))
+	defer server.Close()
+
+	config := APIClientConfig{
+		Headers:     http.Header{"X-Custom": []string{"foo"}},
+		BearerToken: "secret",
+		Timeout:     5 * time.Second,
+	}
+	client, err := config.NewHTTPClient()
+	require.NoError(t, err)
+
+	_, _, err = newShell(server.URL, client).Version()
+	require.NoError(t, err)
+	require.Equal(t, "foo", received.Get("X-Custom"))
+	require.Equal(t, "Bearer secret", received.Get("Authorization"))
+
+	config = APIClientConfig{BasicAuthUser: "user", BasicAuthPassword: "password"}
+	client, err = config.NewHTTPClient()
+	require.NoError(t, err)
+
+	api, err := newHTTPApi(server.URL, client)
+	require.NoError(t, err)
+	err = api.Request("version").Exec(context.Background(), nil)
+	require.NoError(t, err)
+	require.Equal(t, "Basic dXNlcjpwYXNzd29yZA==", received.Get("Authorization"))
+
+	// the Pinning Service token take precedence over the shared credentials
+	ps := newPinningServiceClient(server.URL, "pinsvc", client)
+	_, _ = ps.listPins(context.Background(), psListQuery{})
+	require.Equal(t, "Bearer pinsvc", received.Get("Authorization"))
+}
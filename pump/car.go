@@ -0,0 +1,76 @@
+package pump
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/ipfs/go-cid"
+)
+
+// writeCARv1 serialize the blocks as a CARv1 stream, with a single root
+// being the first block. Importers are expected to not pin the root.
+func writeCARv1(w io.Writer, blocks []Block) error {
+	if len(blocks) == 0 {
+		return fmt.Errorf("no block to write")
+	}
+
+	err := writeCARSection(w, carHeader(blocks[0].CID))
+	if err != nil {
+		return err
+	}
+
+	for _, block := range blocks {
+		section := append(block.CID.Bytes(), block.Data...)
+		err = writeCARSection(w, section)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// carHeader encode in DAG-CBOR the CARv1 header {"roots": [root], "version": 1}
+func carHeader(root cid.Cid) []byte {
+	header := []byte{0xa2} // map of 2 entries
+	header = append(header, 0x65)
+	header = append(header, "roots"...)
+	header = append(header, 0x81) // array of 1 entry
+
+	// CIDs are encoded with the tag 42 on a byte string prefixed by 0x00
+	link := append([]byte{0x00}, root.Bytes()...)
+	header = append(header, 0xd8, 0x2a)
+	header = append(header, cborBytesHeader(len(link))...)
+	header = append(header, link...)
+
+	header = append(header, 0x67)
+	header = append(header, "version"...)
+	header = append(header, 0x01)
+
+	return header
+}
+
+func cborBytesHeader(length int) []byte {
+	switch {
+	case length < 24:
+		return []byte{0x40 | byte(length)}
+	case length < 1<<8:
+		return []byte{0x58, byte(length)}
+	default:
+		return []byte{0x59, byte(length >> 8), byte(length)}
+	}
+}
+
+// writeCARSection write the data prefixed by its length as an unsigned varint
+func writeCARSection(w io.Writer, data []byte) error {
+	buf := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(buf, uint64(len(data)))
+
+	_, err := w.Write(buf[:n])
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
@@ -0,0 +1,205 @@
+package pump
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// clusterClient is a minimal client for the IPFS Cluster REST API
+type clusterClient struct {
+	url    string
+	client *http.Client
+}
+
+// clusterPin is a pin as returned by the IPFS Cluster REST API
+type clusterPin struct {
+	CID                  clusterCID        `json:"cid"`
+	Name                 string            `json:"name"`
+	Mode                 string            `json:"mode"`
+	Allocations          []string          `json:"allocations"`
+	ReplicationFactorMin int               `json:"replication_factor_min"`
+	ReplicationFactorMax int               `json:"replication_factor_max"`
+	Metadata             map[string]string `json:"metadata"`
+}
+
+// clusterCID handle both the plain string form of older clusters and the
+// {"/": "<cid>"} form of newer ones.
+type clusterCID string
+
+func (c *clusterCID) UnmarshalJSON(raw []byte) error {
+	var link struct {
+		Link string `json:"/"`
+	}
+	if err := json.Unmarshal(raw, &link); err == nil {
+		*c = clusterCID(link.Link)
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(raw, &str); err != nil {
+		return err
+	}
+	*c = clusterCID(str)
+	return nil
+}
+
+func newClusterClient(URL string, client *http.Client) *clusterClient {
+	if client == nil {
+		client = &http.Client{}
+	}
+	if !strings.HasPrefix(URL, "http") {
+		URL = "http://" + URL
+	}
+
+	return &clusterClient{
+		url:    strings.TrimSuffix(URL, "/"),
+		client: client,
+	}
+}
+
+// allocations open a stream of the pinset of the cluster. Depending on the
+// version, the cluster answer either a JSON array or a stream of JSON objects.
+func (c *clusterClient) allocations(ctx context.Context) (*clusterPinStream, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/allocations?filter=pin")
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	stream := &clusterPinStream{body: resp.Body, dec: json.NewDecoder(reader)}
+
+	first, err := peekNonSpace(reader)
+	if err == io.EOF {
+		return stream, nil
+	}
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+
+	if first == '[' {
+		// consume the opening bracket
+		_, err = stream.dec.Token()
+		if err != nil {
+			_ = resp.Body.Close()
+			return nil, err
+		}
+	}
+
+	return stream, nil
+}
+
+type clusterPinStream struct {
+	body io.ReadCloser
+	dec  *json.Decoder
+}
+
+// Next return the next pin of the stream, or false when done
+func (s *clusterPinStream) Next() (clusterPin, bool, error) {
+	var pin clusterPin
+	if !s.dec.More() {
+		return pin, false, nil
+	}
+	err := s.dec.Decode(&pin)
+	if err != nil {
+		return pin, false, err
+	}
+	return pin, true, nil
+}
+
+func (s *clusterPinStream) Close() error {
+	return s.body.Close()
+}
+
+// isPinned tells if the cid is part of the cluster pinset
+func (c *clusterClient) isPinned(ctx context.Context, cid string) (bool, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/allocations/"+cid)
+	if err, ok := err.(*clusterError); ok && err.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	_ = resp.Body.Close()
+	return true, nil
+}
+
+// pin add the cid to the cluster pinset with the given settings
+func (c *clusterClient) pin(ctx context.Context, pin clusterPin, userAllocations bool) error {
+	params := url.Values{}
+	if pin.Name != "" {
+		params.Set("name", pin.Name)
+	}
+	if pin.Mode != "" {
+		params.Set("mode", pin.Mode)
+	}
+	if pin.ReplicationFactorMin != 0 {
+		params.Set("replication-min", strconv.Itoa(pin.ReplicationFactorMin))
+	}
+	if pin.ReplicationFactorMax != 0 {
+		params.Set("replication-max", strconv.Itoa(pin.ReplicationFactorMax))
+	}
+	if userAllocations && len(pin.Allocations) > 0 {
+		params.Set("user-allocations", strings.Join(pin.Allocations, ","))
+	}
+	for k, v := range pin.Metadata {
+		params.Set("meta-"+k, v)
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/pins/"+string(pin.CID)+"?"+params.Encode())
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (c *clusterClient) do(ctx context.Context, method, path string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.url+path, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		raw, _ := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return nil, &clusterError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(raw))}
+	}
+
+	return resp, nil
+}
+
+type clusterError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *clusterError) Error() string {
+	return fmt.Sprintf("cluster returned %d: %s", e.StatusCode, e.Message)
+}
+
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch b {
+		case ' ', '\t', '\r', '\n':
+			continue
+		}
+		return b, r.UnreadByte()
+	}
+}
@@ -0,0 +1,186 @@
+package pump
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+// mockCluster is a minimal in-memory IPFS Cluster REST API
+type mockCluster struct {
+	mu     sync.Mutex
+	pins   map[string]map[string]interface{}
+	legacy bool
+	// truncated cut the allocations stream in the middle of a pin
+	truncated bool
+}
+
+func (m *mockCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/allocations":
+		if m.legacy {
+			var pins []interface{}
+			for c, pin := range m.pins {
+				pin["cid"] = c
+				pins = append(pins, pin)
+			}
+			_ = json.NewEncoder(w).Encode(pins)
+			return
+		}
+		for c, pin := range m.pins {
+			pin["cid"] = map[string]string{"/": c}
+			_ = json.NewEncoder(w).Encode(pin)
+		}
+		if m.truncated {
+			_, _ = w.Write([]byte(`{"cid":{"/":"bafy`))
+		}
+
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/allocations/"):
+		if _, ok := m.pins[strings.TrimPrefix(r.URL.Path, "/allocations/")]; !ok {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("{}"))
+
+	case r.Method == http.MethodPost && strings.HasPrefix(r.URL.Path, "/pins/"):
+		q := r.URL.Query()
+		min, _ := strconv.Atoi(q.Get("replication-min"))
+		max, _ := strconv.Atoi(q.Get("replication-max"))
+		meta := map[string]string{}
+		for k := range q {
+			if strings.HasPrefix(k, "meta-") {
+				meta[strings.TrimPrefix(k, "meta-")] = q.Get(k)
+			}
+		}
+		var allocations []string
+		if q.Get("user-allocations") != "" {
+			allocations = strings.Split(q.Get("user-allocations"), ",")
+		}
+		m.pins[strings.TrimPrefix(r.URL.Path, "/pins/")] = map[string]interface{}{
+			"name":                   q.Get("name"),
+			"mode":                   q.Get("mode"),
+			"replication_factor_min": min,
+			"replication_factor_max": max,
+			"allocations":            allocations,
+			"metadata":               meta,
+		}
+		_, _ = w.Write([]byte("{}"))
+
+	default:
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+	}
+}
+
+func TestCluster(t *testing.T) {
+	for _, legacy := range []bool{false, true} {
+		source := &mockCluster{pins: map[string]map[string]interface{}{}, legacy: legacy}
+		pref := cid.Prefix{Version: 1, Codec: cid.DagProtobuf, MhType: multihash.SHA2_256, MhLength: -1}
+		for i := 0; i < 50; i++ {
+			c, err := pref.Sum([]byte(strconv.Itoa(i)))
+			require.NoError(t, err)
+			source.pins[c.String()] = map[string]interface{}{
+				"name":                   fmt.Sprintf("pin-%d", i),
+				"replication_factor_min": 2,
+				"replication_factor_max": 3,
+				"allocations":            []string{"peer1", "peer2"},
+				"metadata":               map[string]string{"i": strconv.Itoa(i)},
+			}
+		}
+		sourceServer := httptest.NewServer(source)
+		target := &mockCluster{pins: map[string]map[string]interface{}{}}
+		targetServer := httptest.NewServer(target)
+
+		enum := NewClusterEnumerator(sourceServer.URL, nil)
+		drain := NewCountedDrain(NewClusterDrain(targetServer.URL, nil, true))
+		failed := NewNullableFileEnumeratorWriter()
+
+		PumpIt(enum, NewNoopCollector(), drain, failed, NewNullProgressWriter(), 4)
+
+		require.Equal(t, 50, enum.TotalCount())
+		require.Equal(t, uint64(50), drain.SuccessfulBlocksCount())
+		require.Equal(t, uint(0), failed.Count())
+		require.Len(t, target.pins, 50)
+
+		for c, pin := range target.pins {
+			expected := source.pins[c]
+			require.NotNil(t, expected)
+			require.Equal(t, expected["name"], pin["name"])
+			require.Equal(t, 2, pin["replication_factor_min"])
+			require.Equal(t, 3, pin["replication_factor_max"])
+			require.Equal(t, []string{"peer1", "peer2"}, pin["allocations"])
+			require.Equal(t, expected["metadata"], pin["metadata"])
+		}
+
+		sourceServer.Close()
+		targetServer.Close()
+	}
+}
+
+func TestClusterDrainMode(t *testing.T) {
+	target := &mockCluster{pins: map[string]map[string]interface{}{}}
+	targetServer := httptest.NewServer(target)
+	defer targetServer.Close()
+
+	drain := NewClusterDrain(targetServer.URL, nil, false)
+	pref := cid.Prefix{Version: 1, Codec: cid.DagProtobuf, MhType: multihash.SHA2_256, MhLength: -1}
+
+	expected := map[string]string{}
+	for i, pinType := range []string{PinTypeRecursive, PinTypeDirect, PinTypeIndirect, "recursive+direct"} {
+		c, err := pref.Sum([]byte(strconv.Itoa(i)))
+		require.NoError(t, err)
+		require.NoError(t, drain.Drain(Block{CID: c, Pin: &PinInfo{Type: pinType}}))
+
+		switch pinType {
+		case PinTypeIndirect:
+		case PinTypeRecursive, PinTypeDirect:
+			expected[c.String()] = pinType
+		default:
+			expected[c.String()] = ""
+		}
+	}
+
+	require.Len(t, target.pins, len(expected))
+	for c, mode := range expected {
+		require.Equal(t, mode, target.pins[c]["mode"], c)
+	}
+}
+
+func TestClusterTruncatedStream(t *testing.T) {
+	source := &mockCluster{pins: map[string]map[string]interface{}{}, truncated: true}
+	pref := cid.Prefix{Version: 1, Codec: cid.DagProtobuf, MhType: multihash.SHA2_256, MhLength: -1}
+	for i := 0; i < 5; i++ {
+		c, err := pref.Sum([]byte(strconv.Itoa(i)))
+		require.NoError(t, err)
+		source.pins[c.String()] = map[string]interface{}{"name": fmt.Sprintf("pin-%d", i)}
+	}
+	server := httptest.NewServer(source)
+	defer server.Close()
+
+	enum := NewClusterEnumerator(server.URL, nil)
+	out := make(chan BlockInfo)
+	require.NoError(t, enum.CIDs(out))
+
+	var cids, errs int
+	for info := range out {
+		if info.Error != nil {
+			errs++
+			continue
+		}
+		cids++
+	}
+	require.Equal(t, 5, cids)
+	require.Equal(t, 1, errs)
+	require.Equal(t, 5, enum.TotalCount())
+}
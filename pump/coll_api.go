@@ -1,6 +1,11 @@
 package pump
 
 import (
+	"context"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/ipfs/go-cid"
 	shell "github.com/ipfs/go-ipfs-api"
 	"github.com/pkg/errors"
 )
@@ -8,15 +13,26 @@ import (
 var _ Collector = &APICollector{}
 
 type APICollector struct {
-	URL string
+	URL    string
+	client *http.Client
+
+	// Offline only read the blocks stored by the node, instead of letting it
+	// fetch the missing ones from the network
+	Offline bool
 }
 
 func NewAPICollector(URL string) *APICollector {
 	return &APICollector{URL: URL}
 }
 
+// NewAPICollectorWithClient create an APICollector using a custom HTTP client,
+// typically to authenticate, see APIClientConfig.
+func NewAPICollectorWithClient(URL string, client *http.Client) *APICollector {
+	return &APICollector{URL: URL, client: client}
+}
+
 func (a *APICollector) Blocks(in <-chan BlockInfo, out chan<- Block) error {
-	s := shell.NewShell(a.URL)
+	s := newShell(a.URL, a.client)
 
 	_, _, err := s.Version()
 	if err != nil {
@@ -25,14 +41,17 @@ func (a *APICollector) Blocks(in <-chan BlockInfo, out chan<- Block) error {
 
 	go func() {
 		for info := range in {
-			data, err := s.BlockGet(info.CID.String())
+			data, err := a.blockGet(s, info.CID)
 			if err != nil {
-				out <- Block{CID: info.CID, Error: err}
+				out <- Block{CID: info.CID, Pin: info.Pin, Size: info.Size, Meta: info.Meta, Error: err}
 				continue
 			}
 
 			out <- Block{
 				CID:  info.CID,
+				Pin:  info.Pin,
+				Size: info.Size,
+				Meta: info.Meta,
 				Data: data,
 			}
 		}
@@ -41,3 +60,22 @@ func (a *APICollector) Blocks(in <-chan BlockInfo, out chan<- Block) error {
 
 	return nil
 }
+
+func (a *APICollector) blockGet(s *shell.Shell, c cid.Cid) ([]byte, error) {
+	if !a.Offline {
+		return s.BlockGet(c.String())
+	}
+
+	resp, err := s.Request("block/get", c.String()).
+		Option("offline", true).
+		Send(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Close()
+
+	if resp.Error != nil {
+		return nil, resp.Error
+	}
+	return ioutil.ReadAll(resp.Output)
+}
@@ -0,0 +1,65 @@
+package pump
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPICollectorOffline(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+	local, err := pref.Sum([]byte("local"))
+	require.NoError(t, err)
+	remote, err := pref.Sum([]byte("remote"))
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v0/version":
+			_, _ = w.Write([]byte(`{"Version": "0.0.0"}`))
+		case "/api/v0/block/get":
+			q := r.URL.Query()
+			switch {
+			case q.Get("arg") == local.String():
+				_, _ = w.Write([]byte("local"))
+			case q.Get("offline") == "true":
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(`{"Message": "block was not found locally (offline)", "Code": 0, "Type": "error"}`))
+			default:
+				_, _ = w.Write([]byte("remote"))
+			}
+		}
+	}))
+	defer server.Close()
+
+	for _, offline := range []bool{false, true} {
+		collector := NewAPICollector(server.URL)
+		collector.Offline = offline
+
+		in := make(chan BlockInfo, 2)
+		in <- BlockInfo{CID: local}
+		in <- BlockInfo{CID: remote}
+		close(in)
+
+		out := make(chan Block)
+		require.NoError(t, collector.Blocks(in, out))
+
+		blocks := map[cid.Cid]Block{}
+		for block := range out {
+			blocks[block.CID] = block
+		}
+
+		require.NoError(t, blocks[local].Error)
+		require.Equal(t, []byte("local"), blocks[local].Data)
+		if offline {
+			require.Error(t, blocks[remote].Error)
+		} else {
+			require.NoError(t, blocks[remote].Error)
+		}
+	}
+}
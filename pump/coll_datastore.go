@@ -22,12 +22,15 @@ func (d *DatastoreCollector) Blocks(in <-chan BlockInfo, out chan<- Block) error
 			key := dshelp.CidToDsKey(info.CID)
 			data, err := d.dstore.Get(key)
 			if err != nil {
-				out <- Block{CID: info.CID, Error: errors.Wrap(err, "datastore collector")}
+				out <- Block{CID: info.CID, Pin: info.Pin, Size: info.Size, Meta: info.Meta, Error: errors.Wrap(err, "datastore collector")}
 				continue
 			}
 
 			out <- Block{
 				CID:  info.CID,
+				Pin:  info.Pin,
+				Size: info.Size,
+				Meta: info.Meta,
 				Data: data,
 			}
 		}
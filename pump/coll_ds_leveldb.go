@@ -0,0 +1,15 @@
+package pump
+
+import (
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/pkg/errors"
+)
+
+func NewLevelDBCollector(path string) (*DatastoreCollector, error) {
+	ds, err := leveldb.NewDatastore(path, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "LevelDB collector")
+	}
+
+	return NewDatastoreCollector(ds), nil
+}
@@ -0,0 +1,12 @@
+package pump
+
+import "github.com/pkg/errors"
+
+func NewPebbleCollector(path string) (*DatastoreCollector, error) {
+	ds, err := newPebbleDatastore(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Pebble collector")
+	}
+
+	return NewDatastoreCollector(ds), nil
+}
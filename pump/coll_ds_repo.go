@@ -0,0 +1,13 @@
+package pump
+
+import "github.com/pkg/errors"
+
+// NewRepoCollector open the blocks datastore of an IPFS repo, as described by its config
+func NewRepoCollector(path string) (*DatastoreCollector, error) {
+	ds, err := openRepoBlockstore(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo collector")
+	}
+
+	return NewDatastoreCollector(ds), nil
+}
@@ -0,0 +1,96 @@
+package pump
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+var _ Collector = &FallbackCollector{}
+
+// FallbackSource is one of the collectors of a FallbackCollector
+type FallbackSource struct {
+	Name      string
+	Collector Collector
+
+	served uint64
+}
+
+// FallbackCollector try several collectors in order, each block being served
+// by the first one that has it. This is useful when the blocks are spread over
+// several stores, for example during a partial migration.
+type FallbackCollector struct {
+	sources []*FallbackSource
+	missed  uint64
+}
+
+func NewFallbackCollector(sources ...*FallbackSource) (*FallbackCollector, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("fallback collector require at least one source")
+	}
+	return &FallbackCollector{sources: sources}, nil
+}
+
+// Blocks chain the collectors: the blocks that a collector failed to retrieve
+// are sent to the next one, the last one reporting the final failures.
+func (f *FallbackCollector) Blocks(in <-chan BlockInfo, out chan<- Block) error {
+	stageIn := in
+
+	for i, source := range f.sources {
+		stageOut := make(chan Block)
+		err := source.Collector.Blocks(stageIn, stageOut)
+		if err != nil {
+			return errors.Wrapf(err, "fallback collector %s", source.Name)
+		}
+
+		last := i == len(f.sources)-1
+		var next chan BlockInfo
+		if !last {
+			next = make(chan BlockInfo)
+		}
+
+		go func(source *FallbackSource) {
+			for block := range stageOut {
+				switch {
+				case block.Error == nil:
+					atomic.AddUint64(&source.served, 1)
+					out <- block
+				case !last:
+					next <- BlockInfo{CID: block.CID, Pin: block.Pin, Size: block.Size, Meta: block.Meta}
+				default:
+					atomic.AddUint64(&f.missed, 1)
+					block.Error = errors.Wrap(block.Error, "no source had the block, last error")
+					out <- block
+				}
+			}
+			if last {
+				close(out)
+			} else {
+				close(next)
+			}
+		}(source)
+
+		stageIn = next
+	}
+
+	return nil
+}
+
+// FallbackStat is the number of blocks served by a source
+type FallbackStat struct {
+	Name   string
+	Served uint64
+}
+
+// Stats return how many blocks each source served, and how many blocks
+// none of them had.
+func (f *FallbackCollector) Stats() (stats []FallbackStat, missed uint64) {
+	for _, source := range f.sources {
+		stats = append(stats, FallbackStat{
+			Name:   source.Name,
+			Served: atomic.LoadUint64(&source.served),
+		})
+	}
+	return stats, atomic.LoadUint64(&f.missed)
+}
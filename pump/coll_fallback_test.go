@@ -0,0 +1,53 @@
+package pump
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallbackCollector(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+
+	// 10 blocks in the first source, 15 in the second one, 5 missing
+	first, second := &sync.Map{}, &sync.Map{}
+	var list strings.Builder
+	for i := 0; i < 30; i++ {
+		data := []byte(fmt.Sprintf("block %d", i))
+		c, err := pref.Sum(data)
+		require.NoError(t, err)
+
+		switch {
+		case i < 10:
+			first.Store(c.String(), data)
+		case i < 25:
+			second.Store(c.String(), data)
+		}
+		list.WriteString(c.String() + "\n")
+	}
+
+	enumerator, err := NewFileEnumerator(strings.NewReader(list.String()))
+	require.NoError(t, err)
+
+	collector, err := NewFallbackCollector(
+		&FallbackSource{Name: "first", Collector: NewMockCollector(first)},
+		&FallbackSource{Name: "second", Collector: NewMockCollector(second)},
+	)
+	require.NoError(t, err)
+
+	drain := NewCountedDrain(newMockDrain())
+	failed := NewNullableFileEnumeratorWriter()
+	PumpIt(enumerator, collector, drain, failed, NewNullProgressWriter(), 3)
+
+	require.Equal(t, uint64(25), drain.SuccessfulBlocksCount())
+	require.Equal(t, uint(5), failed.Count())
+
+	stats, missed := collector.Stats()
+	require.Equal(t, []FallbackStat{{Name: "first", Served: 10}, {Name: "second", Served: 15}}, stats)
+	require.Equal(t, uint64(5), missed)
+}
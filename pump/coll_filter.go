@@ -0,0 +1,60 @@
+package pump
+
+import "sync/atomic"
+
+var _ Collector = &SizeFilterCollector{}
+
+// SizeFilter select the blocks by size, in bytes. A zero Max means no limit.
+type SizeFilter struct {
+	Min int
+	Max int
+}
+
+// Match tells if a block of the given size pass the filter
+func (f SizeFilter) Match(size int) bool {
+	if size < f.Min {
+		return false
+	}
+	if f.Max > 0 && size > f.Max {
+		return false
+	}
+	return true
+}
+
+// SizeFilterCollector only emit the blocks of another collector that pass a
+// SizeFilter. Blocks that failed to be collected are still emitted.
+type SizeFilterCollector struct {
+	collector Collector
+	filter    SizeFilter
+	skipped   uint64
+}
+
+func NewSizeFilterCollector(collector Collector, filter SizeFilter) *SizeFilterCollector {
+	return &SizeFilterCollector{collector: collector, filter: filter}
+}
+
+// Skipped return the number of blocks that didn't pass the filter
+func (s *SizeFilterCollector) Skipped() uint64 {
+	return atomic.LoadUint64(&s.skipped)
+}
+
+func (s *SizeFilterCollector) Blocks(in <-chan BlockInfo, out chan<- Block) error {
+	collected := make(chan Block)
+	err := s.collector.Blocks(in, collected)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for block := range collected {
+			if block.Error == nil && !s.filter.Match(len(block.Data)) {
+				atomic.AddUint64(&s.skipped, 1)
+				continue
+			}
+			out <- block
+		}
+		close(out)
+	}()
+
+	return nil
+}
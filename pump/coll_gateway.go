@@ -0,0 +1,151 @@
+package pump
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+var _ Collector = &GatewayCollector{}
+
+// maxGatewayBlockSize is a safety limit on the size of the responses, in case a
+// gateway ignores the raw format and send a full file instead.
+const maxGatewayBlockSize = 8 << 20
+
+// GatewayCollector retrieve blocks from one or more trustless HTTP gateways.
+// Gateways are tried in turn until one returns the block, and every block is
+// verified against its CID.
+type GatewayCollector struct {
+	URLs     []string
+	Timeout  time.Duration
+	Parallel uint
+
+	client *http.Client
+	next   uint32
+}
+
+func NewGatewayCollector(URLs []string, timeout time.Duration, parallel uint) *GatewayCollector {
+	trimmed := make([]string, len(URLs))
+	for i, u := range URLs {
+		trimmed[i] = strings.TrimSuffix(u, "/")
+	}
+
+	if parallel == 0 {
+		parallel = 1
+	}
+
+	return &GatewayCollector{
+		URLs:     trimmed,
+		Timeout:  timeout,
+		Parallel: parallel,
+		client:   &http.Client{},
+	}
+}
+
+func (g *GatewayCollector) Blocks(in <-chan BlockInfo, out chan<- Block) error {
+	if len(g.URLs) == 0 {
+		return fmt.Errorf("no gateway configured")
+	}
+
+	var wg sync.WaitGroup
+	for i := uint(0); i < g.Parallel; i++ {
+		wg.Add(1)
+		go func() {
+			for info := range in {
+				data, err := g.fetch(info.CID)
+				if err != nil {
+					out <- Block{CID: info.CID, Pin: info.Pin, Size: info.Size, Meta: info.Meta, Error: err}
+					continue
+				}
+
+				out <- Block{
+					CID:  info.CID,
+					Pin:  info.Pin,
+					Size: info.Size,
+					Meta: info.Meta,
+					Data: data,
+				}
+			}
+			wg.Done()
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return nil
+}
+
+// fetch try each gateway in turn, starting from a different one each time to
+// spread the load.
+func (g *GatewayCollector) fetch(c cid.Cid) ([]byte, error) {
+	start := atomic.AddUint32(&g.next, 1)
+
+	var errs []string
+	for i := 0; i < len(g.URLs); i++ {
+		gateway := g.URLs[(int(start)+i)%len(g.URLs)]
+
+		data, err := g.fetchFrom(gateway, c)
+		if err == nil {
+			return data, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", gateway, err))
+	}
+
+	return nil, fmt.Errorf("gateway collector: %s", strings.Join(errs, "; "))
+}
+
+func (g *GatewayCollector) fetchFrom(gateway string, c cid.Cid) ([]byte, error) {
+	ctx := context.Background()
+	if g.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.Timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gateway+"/ipfs/"+c.String()+"?format=raw", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.ipld.raw")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxGatewayBlockSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxGatewayBlockSize {
+		return nil, fmt.Errorf("response bigger than %d bytes", maxGatewayBlockSize)
+	}
+
+	// Trustless: make sure the gateway sent us the block we asked for
+	actual, err := c.Prefix().Sum(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to hash the block")
+	}
+	if !actual.Equals(c) {
+		return nil, fmt.Errorf("hash mismatch, got %s", actual)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,67 @@
+package pump
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayCollector(t *testing.T) {
+	blocks := sync.Map{}
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+
+	serve := func(corrupt bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "raw", r.URL.Query().Get("format"))
+			assert.Equal(t, "application/vnd.ipld.raw", r.Header.Get("Accept"))
+
+			data, ok := blocks.Load(strings.TrimPrefix(r.URL.Path, "/ipfs/"))
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			if corrupt {
+				_, _ = w.Write([]byte("garbage"))
+				return
+			}
+			_, _ = w.Write(data.([]byte))
+		}
+	}
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer down.Close()
+	corrupt := httptest.NewServer(serve(true))
+	defer corrupt.Close()
+	good := httptest.NewServer(serve(false))
+	defer good.Close()
+
+	// Only one of the gateways actually work
+	coll := NewGatewayCollector([]string{down.URL, corrupt.URL + "/", good.URL}, 5*time.Second, 4)
+	drain := NewCountedDrain(newMockDrain())
+
+	failed := NewNullableFileEnumeratorWriter()
+	PumpIt(newMockEnumerator(&blocks, 30, pref), coll, drain, failed, NewNullProgressWriter(), 2)
+
+	require.Equal(t, uint64(30), drain.SuccessfulBlocksCount())
+	require.Equal(t, uint(0), failed.Count())
+
+	// And none of them when the gateway is missing
+	coll = NewGatewayCollector([]string{down.URL, corrupt.URL}, 5*time.Second, 1)
+	drain = NewCountedDrain(newMockDrain())
+
+	failed = NewNullableFileEnumeratorWriter()
+	PumpIt(newMockEnumerator(&blocks, 10, pref), coll, drain, failed, NewNullProgressWriter(), 1)
+
+	require.Equal(t, uint64(0), drain.SuccessfulBlocksCount())
+	require.Equal(t, uint(10), failed.Count())
+}
@@ -0,0 +1,27 @@
+package pump
+
+var _ Collector = &NoopCollector{}
+
+// NoopCollector forward the enumerated CIDs without retrieving any data, for
+// drains that only need the CID and its metadata (pins).
+type NoopCollector struct{}
+
+func NewNoopCollector() *NoopCollector {
+	return &NoopCollector{}
+}
+
+func (n *NoopCollector) Blocks(in <-chan BlockInfo, out chan<- Block) error {
+	go func() {
+		for info := range in {
+			out <- Block{
+				CID:  info.CID,
+				Pin:  info.Pin,
+				Size: info.Size,
+				Meta: info.Meta,
+			}
+		}
+		close(out)
+	}()
+
+	return nil
+}
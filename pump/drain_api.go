@@ -2,6 +2,7 @@ package pump
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/ipfs/interface-go-ipfs-core/path"
 
@@ -22,6 +23,14 @@ func NewAPIDrain(URL string) *APIDrain {
 	}
 }
 
+// NewAPIDrainWithClient create an APIDrain using a custom HTTP client,
+// typically to authenticate, see APIClientConfig.
+func NewAPIDrainWithClient(URL string, client *http.Client) *APIDrain {
+	return &APIDrain{
+		s: newShell(URL, client),
+	}
+}
+
 func NewAPIDrainWithShell(shell *shell.Shell) *APIDrain {
 	return &APIDrain{
 		s: shell,
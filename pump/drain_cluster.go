@@ -0,0 +1,67 @@
+package pump
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var _ Drain = &ClusterDrain{}
+
+// ClusterDrain pin blocks on an IPFS Cluster through its REST API, keeping
+// the pin settings when the enumerator provides them.
+type ClusterDrain struct {
+	client *clusterClient
+
+	// keepAllocations re-use the source allocations as user allocations,
+	// only meaningful if the peers are the same on both clusters.
+	keepAllocations bool
+}
+
+// NewClusterDrain create a ClusterDrain for the given REST API URL.
+// The HTTP client can be nil, or used to authenticate, see APIClientConfig.
+func NewClusterDrain(URL string, client *http.Client, keepAllocations bool) *ClusterDrain {
+	return &ClusterDrain{
+		client:          newClusterClient(URL, client),
+		keepAllocations: keepAllocations,
+	}
+}
+
+func (c *ClusterDrain) Drain(block Block) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	isPinned, err := c.client.isPinned(ctx, block.CID.String())
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("error checking pin status in cluster for cid: %s", block.CID.String()))
+	}
+
+	if isPinned {
+		return nil
+	}
+
+	pin := clusterPin{CID: clusterCID(block.CID.String())}
+	if block.Pin != nil {
+		switch block.Pin.Type {
+		case PinTypeRecursive, PinTypeDirect:
+			pin.Mode = block.Pin.Type
+		case PinTypeIndirect:
+			// pinned through another pin, which will be pinned recursively
+			return nil
+		}
+		pin.Name = block.Pin.Name
+		pin.Metadata = block.Pin.Meta
+		pin.ReplicationFactorMin = block.Pin.ReplicationMin
+		pin.ReplicationFactorMax = block.Pin.ReplicationMax
+		pin.Allocations = block.Pin.Allocations
+	}
+
+	err = c.client.pin(ctx, pin, c.keepAllocations)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("error pinning cid in cluster: %s", block.CID.String()))
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package pump
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	shell "github.com/ipfs/go-ipfs-api"
+	files "github.com/ipfs/go-ipfs-files"
+	"github.com/pkg/errors"
+)
+
+var _ ConfirmingDrain = &DagImportDrain{}
+
+// DagImportDrain buffer the blocks into CAR chunks and stream them to the
+// dag/import API, instead of one block/put call per block. When an import
+// fails, the chunk is retried by halves down to single blocks, so that only
+// the blocks actually refused are reported as failed.
+type DagImportDrain struct {
+	s         *shell.Shell
+	chunkSize int
+
+	mu          sync.Mutex
+	pending     []Block
+	pendingSize int
+	onFailure   func(c cid.Cid)
+	onSuccess   func(c cid.Cid)
+}
+
+// NewDagImportDrain create a DagImportDrain sending chunks of about chunkSize
+// bytes. The HTTP client can be nil, or used to authenticate, see APIClientConfig.
+func NewDagImportDrain(URL string, client *http.Client, chunkSize int) *DagImportDrain {
+	return &DagImportDrain{
+		s:         newShell(URL, client),
+		chunkSize: chunkSize,
+	}
+}
+
+func (d *DagImportDrain) OnFailure(f func(c cid.Cid)) {
+	d.onFailure = f
+}
+
+func (d *DagImportDrain) OnSuccess(f func(c cid.Cid)) {
+	d.onSuccess = f
+}
+
+func (d *DagImportDrain) Drain(block Block) error {
+	d.mu.Lock()
+	d.pending = append(d.pending, block)
+	d.pendingSize += len(block.Data)
+
+	if d.pendingSize < d.chunkSize {
+		d.mu.Unlock()
+		return nil
+	}
+
+	chunk := d.pending
+	d.pending = nil
+	d.pendingSize = 0
+	d.mu.Unlock()
+
+	// Import in the calling worker, so that multiple chunks can be in flight
+	d.importChunk(chunk)
+	return nil
+}
+
+func (d *DagImportDrain) Flush() error {
+	d.mu.Lock()
+	chunk := d.pending
+	d.pending = nil
+	d.pendingSize = 0
+	d.mu.Unlock()
+
+	if len(chunk) > 0 {
+		d.importChunk(chunk)
+	}
+	return nil
+}
+
+func (d *DagImportDrain) importChunk(chunk []Block) {
+	err := d.dagImport(chunk)
+	if err == nil {
+		if d.onSuccess != nil {
+			for _, block := range chunk {
+				d.onSuccess(block.CID)
+			}
+		}
+		return
+	}
+
+	if len(chunk) == 1 {
+		log.Println(errors.Wrapf(err, "failed to import block %s", chunk[0].CID))
+		if d.onFailure != nil {
+			d.onFailure(chunk[0].CID)
+		}
+		return
+	}
+
+	log.Println(errors.Wrapf(err, "failed to import a chunk of %d blocks, retrying by halves", len(chunk)))
+	half := len(chunk) / 2
+	d.importChunk(chunk[:half])
+	d.importChunk(chunk[half:])
+}
+
+func (d *DagImportDrain) dagImport(chunk []Block) error {
+	var car bytes.Buffer
+	err := writeCARv1(&car, chunk)
+	if err != nil {
+		return err
+	}
+
+	fr := files.NewBytesFile(car.Bytes())
+	slf := files.NewSliceDirectory([]files.DirEntry{files.FileEntry("", fr)})
+	fileReader := files.NewMultiFileReader(slf, true)
+
+	resp, err := d.s.Request("dag/import").
+		Option("pin-roots", false).
+		Body(fileReader).
+		Send(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// Errors can happen while streaming the response, so read it completely
+	closeErr := resp.Close()
+	if resp.Error != nil {
+		return resp.Error
+	}
+	return closeErr
+}
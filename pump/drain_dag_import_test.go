@@ -0,0 +1,135 @@
+package pump
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+// mockDagImport count the imported blocks and refuse the whole CAR when it
+// holds a refused block, or any CAR when failing.
+type mockDagImport struct {
+	imported, requests uint64
+	failing            bool
+	refused            map[cid.Cid]bool
+}
+
+func (m *mockDagImport) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddUint64(&m.requests, 1)
+	if m.failing {
+		http.Error(w, `{"Message": "import failed", "Code": 0, "Type": "error"}`, http.StatusInternalServerError)
+		return
+	}
+
+	mr, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, err := mr.NextPart()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// count the sections of the CAR, minus the header
+	reader := bufio.NewReader(file)
+	sections := uint64(0)
+	for {
+		length, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			break
+		}
+		section := make([]byte, length)
+		_, err = io.ReadFull(reader, section)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if sections > 0 {
+			_, c, err := cid.CidFromBytes(section)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if m.refused[c] {
+				http.Error(w, `{"Message": "block refused", "Code": 0, "Type": "error"}`, http.StatusInternalServerError)
+				return
+			}
+		}
+		sections++
+	}
+	atomic.AddUint64(&m.imported, sections-1)
+}
+
+func TestDagImportDrain(t *testing.T) {
+	mock := &mockDagImport{}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+
+	// mock blocks are 10kB, so 5 blocks per chunk
+	blocks := sync.Map{}
+	failed := NewNullableFileEnumeratorWriter()
+	drain := NewDagImportDrain(server.URL, nil, 50000)
+	PumpIt(newMockEnumerator(&blocks, 23, pref), NewMockCollector(&blocks), drain, failed, NewNullProgressWriter(), 3)
+
+	require.Equal(t, uint64(23), mock.imported)
+	require.Equal(t, uint64(5), mock.requests)
+	require.Equal(t, uint(0), failed.Count())
+
+	// every block of the failed chunks must be reported
+	mock.failing = true
+	failed = NewNullableFileEnumeratorWriter()
+	drain = NewDagImportDrain(server.URL, nil, 50000)
+	PumpIt(newMockEnumerator(&blocks, 23, pref), NewMockCollector(&blocks), drain, failed, NewNullProgressWriter(), 3)
+
+	require.Equal(t, uint(23), failed.Count())
+}
+
+func TestDagImportDrainRefusedBlocks(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+
+	var blocks []Block
+	for i := 0; i < 10; i++ {
+		data := []byte(strconv.Itoa(i))
+		c, err := pref.Sum(data)
+		require.NoError(t, err)
+		blocks = append(blocks, Block{CID: c, Data: data})
+	}
+
+	mock := &mockDagImport{refused: map[cid.Cid]bool{blocks[2].CID: true, blocks[7].CID: true}}
+	server := httptest.NewServer(mock)
+	defer server.Close()
+
+	// a single chunk, retried by halves
+	drain := NewDagImportDrain(server.URL, nil, 1<<20)
+	var failed []cid.Cid
+	drain.OnFailure(func(c cid.Cid) {
+		failed = append(failed, c)
+	})
+	succeeded := 0
+	drain.OnSuccess(func(c cid.Cid) {
+		succeeded++
+	})
+
+	for _, block := range blocks {
+		require.NoError(t, drain.Drain(block))
+	}
+	require.NoError(t, drain.Flush())
+
+	require.Equal(t, []cid.Cid{blocks[2].CID, blocks[7].CID}, failed)
+	require.Equal(t, uint64(8), mock.imported)
+	require.Equal(t, 8, succeeded)
+}
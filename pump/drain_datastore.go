@@ -16,6 +16,11 @@ func NewDatastoreDrain(dstore ds.Datastore) *DatastoreDrain {
 	return &DatastoreDrain{dstore: dstore}
 }
 
+// Datastore return the underlying datastore
+func (d *DatastoreDrain) Datastore() ds.Datastore {
+	return d.dstore
+}
+
 func (d *DatastoreDrain) Drain(block Block) error {
 	key := dshelp.CidToDsKey(block.CID)
 	err := d.dstore.Put(key, block.Data)
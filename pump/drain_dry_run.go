@@ -0,0 +1,220 @@
+package pump
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+var _ Drain = &DryRunDrain{}
+
+// DryRunDrain doesn't write anything, but build an inventory of the blocks
+// that would have been written. As the enumerator and the collector still run,
+// it double as a read-only health check of the source.
+type DryRunDrain struct {
+	mu        sync.Mutex
+	inventory Inventory
+}
+
+// NewDryRunDrain create a DryRunDrain keeping track of the given number of
+// largest blocks.
+func NewDryRunDrain(largest int) *DryRunDrain {
+	return &DryRunDrain{
+		inventory: Inventory{
+			Codecs:       make(map[string]*InventoryCount),
+			Multihashes:  make(map[string]*InventoryCount),
+			Versions:     make(map[string]*InventoryCount),
+			Histogram:    make([]InventoryCount, len(inventorySizeBuckets)+1),
+			largestCount: largest,
+		},
+	}
+}
+
+func (d *DryRunDrain) Drain(block Block) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.inventory.add(block)
+	return nil
+}
+
+// Inventory return the inventory of the drained blocks, once the pump is done
+func (d *DryRunDrain) Inventory() *Inventory {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	inv := d.inventory
+	inv.Largest = d.inventory.largest.sorted()
+	return &inv
+}
+
+type InventoryCount struct {
+	Blocks uint64
+	Bytes  uint64
+}
+
+func (c *InventoryCount) add(size int) {
+	c.Blocks++
+	c.Bytes += uint64(size)
+}
+
+type InventoryBlock struct {
+	CID  cid.Cid
+	Size int
+}
+
+// inventorySizeBuckets are the upper bounds of the size histogram. 2MiB is the
+// maximum block size accepted by bitswap.
+var inventorySizeBuckets = []int{1 << 10, 4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20, 2 << 20}
+
+// Inventory is the report of a dry run
+type Inventory struct {
+	Total       InventoryCount
+	Codecs      map[string]*InventoryCount
+	Multihashes map[string]*InventoryCount
+	Versions    map[string]*InventoryCount
+
+	// Histogram count the blocks by size, using inventorySizeBuckets
+	Histogram []InventoryCount
+
+	// Largest are the largest blocks, biggest first
+	Largest []InventoryBlock
+
+	largest      largestBlocks
+	largestCount int
+}
+
+func (inv *Inventory) add(block Block) {
+	size := len(block.Data)
+	pref := block.CID.Prefix()
+
+	inv.Total.add(size)
+	inventoryCount(inv.Codecs, codecName(pref.Codec)).add(size)
+	inventoryCount(inv.Multihashes, multihashName(pref.MhType)).add(size)
+	inventoryCount(inv.Versions, fmt.Sprintf("v%d", pref.Version)).add(size)
+
+	bucket := sort.Search(len(inventorySizeBuckets), func(i int) bool {
+		return size <= inventorySizeBuckets[i]
+	})
+	inv.Histogram[bucket].add(size)
+
+	if inv.largestCount > 0 {
+		heap.Push(&inv.largest, InventoryBlock{CID: block.CID, Size: size})
+		if inv.largest.Len() > inv.largestCount {
+			heap.Pop(&inv.largest)
+		}
+	}
+}
+
+// WriteReport write a human readable report of the inventory
+func (inv *Inventory) WriteReport(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "Total\t%d blocks\t%d bytes\t\n", inv.Total.Blocks, inv.Total.Bytes)
+
+	for _, section := range []struct {
+		title  string
+		counts map[string]*InventoryCount
+	}{
+		{"CID version", inv.Versions},
+		{"Codec", inv.Codecs},
+		{"Multihash", inv.Multihashes},
+	} {
+		fmt.Fprintf(tw, "\n%s\tblocks\tbytes\t\n", section.title)
+
+		keys := make([]string, 0, len(section.counts))
+		for k := range section.counts {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			fmt.Fprintf(tw, "%s\t%d\t%d\t\n", k, section.counts[k].Blocks, section.counts[k].Bytes)
+		}
+	}
+
+	fmt.Fprintf(tw, "\nSize\tblocks\tbytes\t\n")
+	for i, count := range inv.Histogram {
+		var label string
+		switch {
+		case i == 0:
+			label = "<= " + formatSize(inventorySizeBuckets[0])
+		case i == len(inventorySizeBuckets):
+			label = "> " + formatSize(inventorySizeBuckets[i-1])
+		default:
+			label = formatSize(inventorySizeBuckets[i-1]) + " - " + formatSize(inventorySizeBuckets[i])
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t\n", label, count.Blocks, count.Bytes)
+	}
+
+	if len(inv.Largest) > 0 {
+		fmt.Fprintf(tw, "\nLargest blocks\tbytes\t\n")
+		for _, block := range inv.Largest {
+			fmt.Fprintf(tw, "%s\t%d\t\n", block.CID, block.Size)
+		}
+	}
+
+	return tw.Flush()
+}
+
+func inventoryCount(counts map[string]*InventoryCount, key string) *InventoryCount {
+	count, ok := counts[key]
+	if !ok {
+		count = &InventoryCount{}
+		counts[key] = count
+	}
+	return count
+}
+
+func codecName(codec uint64) string {
+	if name, ok := cid.CodecToStr[codec]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", codec)
+}
+
+func multihashName(code uint64) string {
+	if name, ok := multihash.Codes[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%x", code)
+}
+
+func formatSize(size int) string {
+	switch {
+	case size >= 1<<20 && size%(1<<20) == 0:
+		return fmt.Sprintf("%dMiB", size>>20)
+	case size >= 1<<10 && size%(1<<10) == 0:
+		return fmt.Sprintf("%dKiB", size>>10)
+	default:
+		return fmt.Sprintf("%dB", size)
+	}
+}
+
+// largestBlocks is a min-heap of blocks by size, to keep the N largest
+type largestBlocks []InventoryBlock
+
+func (l largestBlocks) Len() int            { return len(l) }
+func (l largestBlocks) Less(i, j int) bool  { return l[i].Size < l[j].Size }
+func (l largestBlocks) Swap(i, j int)       { l[i], l[j] = l[j], l[i] }
+func (l *largestBlocks) Push(x interface{}) { *l = append(*l, x.(InventoryBlock)) }
+func (l *largestBlocks) Pop() interface{} {
+	old := *l
+	x := old[len(old)-1]
+	*l = old[:len(old)-1]
+	return x
+}
+
+// sorted return a copy of the blocks, biggest first
+func (l largestBlocks) sorted() []InventoryBlock {
+	res := make([]InventoryBlock, len(l))
+	copy(res, l)
+	sort.Slice(res, func(i, j int) bool { return res[i].Size > res[j].Size })
+	return res
+}
@@ -0,0 +1,57 @@
+package pump
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDryRunDrain(t *testing.T) {
+	drain := NewDryRunDrain(2)
+
+	v0 := cid.Prefix{Version: 0, Codec: cid.DagProtobuf, MhType: multihash.SHA2_256, MhLength: -1}
+	v1 := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.BLAKE2B_MIN + 31, MhLength: -1}
+
+	var biggest cid.Cid
+	for i, size := range []int{10, 2000, 5000, 300000, 3 << 20} {
+		data := bytes.Repeat([]byte{byte(i)}, size)
+		pref := v1
+		if i%2 == 0 {
+			pref = v0
+		}
+		c, err := pref.Sum(data)
+		require.NoError(t, err)
+		if size == 3<<20 {
+			biggest = c
+		}
+		require.NoError(t, drain.Drain(Block{CID: c, Data: data}))
+	}
+
+	inv := drain.Inventory()
+	require.Equal(t, uint64(5), inv.Total.Blocks)
+	require.Equal(t, uint64(10+2000+5000+300000+3<<20), inv.Total.Bytes)
+
+	require.Equal(t, uint64(3), inv.Versions["v0"].Blocks)
+	require.Equal(t, uint64(2), inv.Codecs["raw"].Blocks)
+	require.Equal(t, uint64(3), inv.Codecs["protobuf"].Blocks)
+	require.Equal(t, uint64(3), inv.Multihashes["sha2-256"].Blocks)
+	require.Equal(t, uint64(2), inv.Multihashes["blake2b-256"].Blocks)
+
+	var histogram []uint64
+	for _, count := range inv.Histogram {
+		histogram = append(histogram, count.Blocks)
+	}
+	require.Equal(t, []uint64{1, 1, 1, 0, 0, 1, 0, 1}, histogram)
+
+	require.Len(t, inv.Largest, 2)
+	require.Equal(t, biggest, inv.Largest[0].CID)
+	require.Equal(t, 300000, inv.Largest[1].Size)
+
+	var report bytes.Buffer
+	require.NoError(t, inv.WriteReport(&report))
+	require.Contains(t, report.String(), biggest.String())
+	require.Contains(t, report.String(), "> 2MiB")
+}
@@ -0,0 +1,12 @@
+package pump
+
+import "github.com/pkg/errors"
+
+func NewPebbleDrain(path string) (*DatastoreDrain, error) {
+	ds, err := newPebbleDatastore(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Pebble drain")
+	}
+
+	return NewDatastoreDrain(ds), nil
+}
@@ -0,0 +1,13 @@
+package pump
+
+import "github.com/pkg/errors"
+
+// NewRepoDrain open the blocks datastore of an IPFS repo, as described by its config
+func NewRepoDrain(path string) (*DatastoreDrain, error) {
+	ds, err := openRepoBlockstore(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo drain")
+	}
+
+	return NewDatastoreDrain(ds), nil
+}
@@ -3,57 +3,60 @@ package pump
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/ipfs/interface-go-ipfs-core/path"
 
-	client "github.com/ipfs/go-ipfs-http-client"
 	iface "github.com/ipfs/interface-go-ipfs-core"
 	"github.com/ipfs/interface-go-ipfs-core/options"
-	"github.com/multiformats/go-multiaddr"
 )
 
 var _ Drain = &PinDrain{}
 
+// PinDrain replicate the pins on a node. The pin type is taken from the
+// enumerator when it knows it, or else queried from the checker node.
 type PinDrain struct {
 	pinner  iface.PinAPI
 	checker iface.PinAPI
 }
 
 func NewPinDrain(pinner, checker string) (*PinDrain, error) {
-	pinnerMA, err := multiaddr.NewMultiaddr(pinner)
-	if err != nil {
-		return nil, err
-	}
+	return NewPinDrainWithClient(pinner, checker, nil)
+}
 
-	pinnerCli, err := client.NewApi(pinnerMA)
+// NewPinDrainWithClient create a PinDrain using a custom HTTP client, typically
+// to authenticate, see APIClientConfig. The APIs can be given as multiaddr or URL.
+// The checker is optional if the enumerator provides the pin types.
+func NewPinDrainWithClient(pinner, checker string, client *http.Client) (*PinDrain, error) {
+	pinnerCli, err := newHTTPApi(pinner, client)
 	if err != nil {
 		return nil, err
 	}
 
-	chackerMA, err := multiaddr.NewMultiaddr(checker)
-	if err != nil {
-		return nil, err
+	drain := &PinDrain{
+		pinner: pinnerCli.Pin(),
 	}
 
-	checkerCli, err := client.NewApi(chackerMA)
-	if err != nil {
-		return nil, err
+	if checker != "" {
+		checkerCli, err := newHTTPApi(checker, client)
+		if err != nil {
+			return nil, err
+		}
+		drain.checker = checkerCli.Pin()
 	}
 
-	return &PinDrain{
-		pinner:  pinnerCli.Pin(),
-		checker: checkerCli.Pin(),
-	}, nil
+	return drain, nil
 }
 
 func (a *PinDrain) Drain(block Block) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
 	defer cancel()
 
-	reason, isPinned, err := a.pinner.IsPinned(ctx, path.IpfsPath(block.CID))
+	_, isPinned, err := a.pinner.IsPinned(ctx, path.IpfsPath(block.CID))
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("error checking pin status in pinner for cid: %s", block.CID.String()))
 	}
@@ -62,24 +65,53 @@ func (a *PinDrain) Drain(block Block) error {
 		return nil
 	}
 
-	reason, isPinned, err = a.checker.IsPinned(ctx, path.IpfsPath(block.CID))
-	if err != nil {
-		return errors.Wrap(err, fmt.Sprintf("error checking pin status in checker for cid: %s", block.CID.String()))
+	var pinType string
+	if block.Pin != nil && block.Pin.Type != "" {
+		pinType = block.Pin.Type
+	} else {
+		pinType, err = a.checkPinType(ctx, block)
+		if err != nil {
+			return err
+		}
 	}
 
-	if !isPinned {
-		// It was not pinned originally, so do nothing
+	switch pinType {
+	case PinTypeRecursive, PinTypeDirect:
+	default:
+		// It was not pinned originally, or only indirectly through
+		// another pin, so do nothing
 		return nil
 	}
 
-	recursivePin := false
-	if reason == "recursive" {
-		recursivePin = true
-	}
-
-	err = a.pinner.Add(ctx, path.IpfsPath(block.CID), options.Pin.Recursive(recursivePin))
+	err = a.pinner.Add(ctx, path.IpfsPath(block.CID), options.Pin.Recursive(pinType == PinTypeRecursive))
 	if err != nil {
 		return errors.Wrap(err, fmt.Sprintf("error pinning cid: %s", block.CID.String()))
 	}
 	return nil
 }
+
+// checkPinType query the checker for the original pin type of a block
+func (a *PinDrain) checkPinType(ctx context.Context, block Block) (string, error) {
+	if a.checker == nil {
+		return "", fmt.Errorf("unknown pin type for cid %s and no checker configured", block.CID.String())
+	}
+
+	reason, isPinned, err := a.checker.IsPinned(ctx, path.IpfsPath(block.CID))
+	if err != nil {
+		return "", errors.Wrap(err, fmt.Sprintf("error checking pin status in checker for cid: %s", block.CID.String()))
+	}
+
+	if !isPinned {
+		return "", nil
+	}
+
+	switch {
+	case reason == PinTypeRecursive:
+		return PinTypeRecursive, nil
+	case strings.HasPrefix(reason, PinTypeIndirect):
+		// "indirect through <cid>", covered by another pin
+		return PinTypeIndirect, nil
+	default:
+		return PinTypeDirect, nil
+	}
+}
@@ -0,0 +1,62 @@
+package pump
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	iface "github.com/ipfs/interface-go-ipfs-core"
+	"github.com/ipfs/interface-go-ipfs-core/options"
+	"github.com/ipfs/interface-go-ipfs-core/path"
+	"github.com/stretchr/testify/require"
+)
+
+// mockPinAPI answer IsPinned with fixed reasons and record the added pins
+type mockPinAPI struct {
+	iface.PinAPI
+	reasons map[cid.Cid]string
+	added   map[cid.Cid]bool
+}
+
+func (m *mockPinAPI) IsPinned(_ context.Context, p path.Path, _ ...options.PinIsPinnedOption) (string, bool, error) {
+	reason, ok := m.reasons[p.(path.Resolved).Cid()]
+	return reason, ok, nil
+}
+
+func (m *mockPinAPI) Add(_ context.Context, p path.Path, opts ...options.PinAddOption) error {
+	settings, err := options.PinAddOptions(opts...)
+	if err != nil {
+		return err
+	}
+	m.added[p.(path.Resolved).Cid()] = settings.Recursive
+	return nil
+}
+
+func TestPinDrainCheckedTypes(t *testing.T) {
+	var cids []cid.Cid
+	for _, s := range []string{
+		"QmcbQviBDZ55DxF83rTJ7fQ9PgvbpSnhRany1FXhDD11UQ",
+		"QmcZixk3G7mmDBE7oR7MkMCeGQkzuaA5e4GS3y7szp5Tbx",
+		"QmZtUAkrdTjSh2GbvkkHcf8Y5dqh5qvZDW1eH2SsnXjJR3",
+		"QmSDJ8nvXh4KmpYNGFFwwKuYQRz1ZAFfUDBCRNEnmDUQNn",
+	} {
+		c, err := cid.Parse(s)
+		require.NoError(t, err)
+		cids = append(cids, c)
+	}
+	recursive, direct, indirect, unpinned := cids[0], cids[1], cids[2], cids[3]
+
+	pinner := &mockPinAPI{reasons: map[cid.Cid]string{}, added: map[cid.Cid]bool{}}
+	checker := &mockPinAPI{reasons: map[cid.Cid]string{
+		recursive: PinTypeRecursive,
+		direct:    PinTypeDirect,
+		indirect:  "indirect through " + recursive.String(),
+	}}
+	drain := &PinDrain{pinner: pinner, checker: checker}
+
+	for _, c := range []cid.Cid{recursive, direct, indirect, unpinned} {
+		require.NoError(t, drain.Drain(Block{CID: c}))
+	}
+
+	require.Equal(t, map[cid.Cid]bool{recursive: true, direct: false}, pinner.added)
+}
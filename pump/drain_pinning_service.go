@@ -0,0 +1,55 @@
+package pump
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var _ Drain = &PinningServiceDrain{}
+
+// PinningServiceDrain create pins on an IPFS Pinning Service API endpoint,
+// keeping the pin name and meta when the enumerator provides them.
+type PinningServiceDrain struct {
+	client *pinningServiceClient
+}
+
+// NewPinningServiceDrain create a PinningServiceDrain for the given API URL.
+// The HTTP client can be nil, or used to configure TLS and timeouts, see APIClientConfig.
+func NewPinningServiceDrain(URL, token string, client *http.Client) *PinningServiceDrain {
+	return &PinningServiceDrain{
+		client: newPinningServiceClient(URL, token, client),
+	}
+}
+
+func (p *PinningServiceDrain) Drain(block Block) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	existing, err := p.client.listPins(ctx, psListQuery{
+		CIDs:   []string{block.CID.String()},
+		Status: []string{"queued", "pinning", "pinned"},
+	})
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("error checking pin status for cid: %s", block.CID.String()))
+	}
+
+	if existing.Count > 0 {
+		return nil
+	}
+
+	pin := psPin{CID: block.CID.String()}
+	if block.Pin != nil {
+		pin.Name = block.Pin.Name
+		pin.Meta = block.Pin.Meta
+	}
+
+	_, err = p.client.addPin(ctx, pin)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("error pinning cid: %s", block.CID.String()))
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package pump
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+var _ ConfirmingDrain = &SizeLimitDrain{}
+
+// SizeLimitDrain keep the blocks above a maximum size away from a drain,
+// before spending the bandwidth to have them refused by the destination.
+// Oversized blocks go to a secondary drain if any, otherwise to a report,
+// otherwise they fail.
+type SizeLimitDrain struct {
+	drain     Drain
+	max       int
+	secondary Drain
+
+	mu     sync.Mutex
+	report *bufio.Writer
+
+	oversized uint64
+	onSuccess func(c cid.Cid)
+}
+
+// NewSizeLimitDrain create a SizeLimitDrain. Both secondary and report can be nil.
+func NewSizeLimitDrain(drain Drain, max int, secondary Drain, report io.Writer) (*SizeLimitDrain, error) {
+	if max <= 0 {
+		return nil, fmt.Errorf("invalid maximum block size %d", max)
+	}
+
+	s := &SizeLimitDrain{
+		drain:     drain,
+		max:       max,
+		secondary: secondary,
+	}
+	if report != nil {
+		s.report = bufio.NewWriter(report)
+	}
+	return s, nil
+}
+
+func (s *SizeLimitDrain) OnFailure(f func(c cid.Cid)) {
+	if batch, ok := s.drain.(BatchDrain); ok {
+		batch.OnFailure(f)
+	}
+	if batch, ok := s.secondary.(BatchDrain); ok {
+		batch.OnFailure(f)
+	}
+}
+
+func (s *SizeLimitDrain) OnSuccess(f func(c cid.Cid)) {
+	s.onSuccess = f
+	if batch, ok := s.drain.(ConfirmingDrain); ok {
+		batch.OnSuccess(f)
+	}
+	if batch, ok := s.secondary.(ConfirmingDrain); ok {
+		batch.OnSuccess(f)
+	}
+}
+
+func (s *SizeLimitDrain) Drain(block Block) error {
+	if len(block.Data) <= s.max {
+		return s.confirm(s.drain, block.CID, s.drain.Drain(block))
+	}
+
+	atomic.AddUint64(&s.oversized, 1)
+
+	switch {
+	case s.secondary != nil:
+		return errors.Wrap(s.confirm(s.secondary, block.CID, s.secondary.Drain(block)), "oversized block")
+
+	case s.report != nil:
+		s.mu.Lock()
+		_, err := fmt.Fprintf(s.report, "%s %d\n", block.CID, len(block.Data))
+		s.mu.Unlock()
+
+		return errors.Wrap(s.confirm(nil, block.CID, err), "oversized block report")
+
+	default:
+		return fmt.Errorf("block of %d bytes is above the limit of %d bytes", len(block.Data), s.max)
+	}
+}
+
+// confirm report a block written by a drain that doesn't do it by itself
+func (s *SizeLimitDrain) confirm(drain Drain, c cid.Cid, err error) error {
+	if _, ok := drain.(BatchDrain); !ok && err == nil && s.onSuccess != nil {
+		s.onSuccess(c)
+	}
+	return err
+}
+
+func (s *SizeLimitDrain) Flush() error {
+	if batch, ok := s.drain.(BatchDrain); ok {
+		if err := batch.Flush(); err != nil {
+			return err
+		}
+	}
+	if batch, ok := s.secondary.(BatchDrain); ok {
+		if err := batch.Flush(); err != nil {
+			return errors.Wrap(err, "oversized blocks drain")
+		}
+	}
+
+	if s.report != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return errors.Wrap(s.report.Flush(), "oversized block report")
+	}
+	return nil
+}
+
+// Oversized return the number of blocks above the limit
+func (s *SizeLimitDrain) Oversized() uint64 {
+	return atomic.LoadUint64(&s.oversized)
+}
@@ -0,0 +1,63 @@
+package pump
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizeLimitDrain(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+
+	var blocks []Block
+	for _, size := range []int{10, 100, 101, 1000} {
+		data := bytes.Repeat([]byte{'a'}, size)
+		c, err := pref.Sum(data)
+		require.NoError(t, err)
+		blocks = append(blocks, Block{CID: c, Data: data})
+	}
+
+	drainAll := func(drain Drain) int {
+		failed := 0
+		for _, block := range blocks {
+			if drain.Drain(block) != nil {
+				failed++
+			}
+		}
+		return failed
+	}
+
+	// oversized blocks fail without reaching the drain
+	main := newMockDrain()
+	limited, err := NewSizeLimitDrain(main, 100, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, 2, drainAll(limited))
+	require.Equal(t, uint64(2), main.Drained)
+	require.Equal(t, uint64(2), limited.Oversized())
+
+	// oversized blocks go to the secondary drain
+	main, secondary := newMockDrain(), newMockDrain()
+	limited, err = NewSizeLimitDrain(main, 100, secondary, nil)
+	require.NoError(t, err)
+	require.Equal(t, 0, drainAll(limited))
+	require.Equal(t, uint64(2), main.Drained)
+	require.Equal(t, uint64(2), secondary.Drained)
+
+	// oversized blocks are reported
+	var report strings.Builder
+	main = newMockDrain()
+	limited, err = NewSizeLimitDrain(main, 100, nil, &report)
+	require.NoError(t, err)
+	require.Equal(t, 0, drainAll(limited))
+	require.NoError(t, limited.Flush())
+	require.Equal(t, uint64(2), main.Drained)
+	require.Equal(t, fmt.Sprintf("%s 101\n%s 1000\n", blocks[2].CID, blocks[3].CID), report.String())
+
+	_, err = NewSizeLimitDrain(main, 0, nil, nil)
+	require.Error(t, err)
+}
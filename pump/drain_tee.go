@@ -0,0 +1,222 @@
+package pump
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+var _ ConfirmingDrain = &TeeDrain{}
+
+// Tee failure policies
+const (
+	// TeeFailAny report a block as failed as soon as one destination failed
+	TeeFailAny = "any"
+	// TeeFailAll report a block as failed only if all the destinations failed
+	TeeFailAll = "all"
+)
+
+// TeeDestination is one of the drains of a TeeDrain
+type TeeDestination struct {
+	Name  string
+	Drain Drain
+
+	// Failed receive the blocks that failed for this destination only. Can be nil.
+	Failed FailedBlocksWriter
+}
+
+// TeeDrain write each block to several drains, so that the source is read
+// only once. Failures are reported per destination, and globally according
+// to the failure policy.
+type TeeDrain struct {
+	destinations []TeeDestination
+	policy       string
+
+	mu        sync.Mutex
+	outcomes  map[cid.Cid]*teeOutcome
+	onFailure func(c cid.Cid)
+	onSuccess func(c cid.Cid)
+}
+
+// teeOutcome is the state of a block, kept until the outcome is known for all
+// the destinations
+type teeOutcome struct {
+	failed  int
+	settled int
+}
+
+// NewTeeDrain create a TeeDrain with the given failure policy, TeeFailAny or TeeFailAll.
+func NewTeeDrain(policy string, destinations ...TeeDestination) (*TeeDrain, error) {
+	if policy != TeeFailAny && policy != TeeFailAll {
+		return nil, fmt.Errorf("unknown tee failure policy: %s", policy)
+	}
+	if len(destinations) == 0 {
+		return nil, fmt.Errorf("tee drain require at least one destination")
+	}
+
+	t := &TeeDrain{
+		destinations: destinations,
+		policy:       policy,
+		outcomes:     make(map[cid.Cid]*teeOutcome),
+	}
+
+	// batch drains report their outcomes later, route them through the same accounting
+	for _, dest := range destinations {
+		if batch, ok := dest.Drain.(BatchDrain); ok {
+			dest := dest
+			batch.OnFailure(func(c cid.Cid) {
+				t.report(c, t.settle(c, []TeeDestination{dest}, 0))
+			})
+		}
+		if batch, ok := dest.Drain.(ConfirmingDrain); ok {
+			batch.OnSuccess(func(c cid.Cid) {
+				t.report(c, t.settle(c, nil, 1))
+			})
+		}
+	}
+
+	return t, nil
+}
+
+func (t *TeeDrain) OnFailure(f func(c cid.Cid)) {
+	t.onFailure = f
+}
+
+func (t *TeeDrain) OnSuccess(f func(c cid.Cid)) {
+	t.onSuccess = f
+}
+
+func (t *TeeDrain) Drain(block Block) error {
+	var errs []string
+	var failed []TeeDestination
+	succeeded := 0
+
+	for _, dest := range t.destinations {
+		err := dest.Drain.Drain(block)
+		if err == nil {
+			// a batch drain report the outcome later
+			if _, ok := dest.Drain.(BatchDrain); !ok {
+				succeeded++
+			}
+			continue
+		}
+
+		log.Println(errors.Wrapf(err, "tee: failed to push block %s to %s", block.CID.String(), dest.Name))
+		errs = append(errs, dest.Name)
+		failed = append(failed, dest)
+	}
+
+	outcome := t.settle(block.CID, failed, succeeded)
+	if outcome == teeFailed {
+		return fmt.Errorf("tee: failed to push block to %s", strings.Join(errs, ", "))
+	}
+	t.report(block.CID, outcome)
+	return nil
+}
+
+func (t *TeeDrain) Flush() error {
+	var errs []string
+
+	for _, dest := range t.destinations {
+		if batch, ok := dest.Drain.(BatchDrain); ok {
+			err := batch.Flush()
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", dest.Name, err))
+			}
+		}
+	}
+
+	// write out the per destination failures
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// the outcome of every block is now known, even for the batch
+	// destinations that don't confirm their writes
+	t.outcomes = make(map[cid.Cid]*teeOutcome)
+
+	for _, dest := range t.destinations {
+		if dest.Failed == nil {
+			continue
+		}
+		err := dest.Failed.Flush()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", dest.Name, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("tee: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Global outcomes of a block, according to the failure policy
+const (
+	teeUnknown = iota
+	teeFailed
+	teeSucceeded
+)
+
+// settle record the outcome of a block for some destinations, and tell if
+// the block has to be reported globally, as failed or succeeded, according
+// to the policy. The blocks are tracked until the outcome is known for all
+// the destinations.
+func (t *TeeDrain) settle(c cid.Cid, failed []TeeDestination, succeeded int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, dest := range failed {
+		if dest.Failed == nil {
+			continue
+		}
+		_, err := dest.Failed.Write(c)
+		if err != nil {
+			log.Println(fmt.Errorf("failed to write failed block %s for %s", c.String(), dest.Name))
+		}
+	}
+
+	outcome, ok := t.outcomes[c]
+	if !ok {
+		outcome = &teeOutcome{}
+		t.outcomes[c] = outcome
+	}
+
+	before := outcome.failed
+	outcome.failed += len(failed)
+	outcome.settled += len(failed) + succeeded
+
+	settled := outcome.settled >= len(t.destinations)
+	if settled {
+		// nothing more can happen to this block
+		delete(t.outcomes, c)
+	}
+
+	// the number of failures to fail the block globally
+	threshold := 1
+	if t.policy == TeeFailAll {
+		threshold = len(t.destinations)
+	}
+
+	switch {
+	case before < threshold && outcome.failed >= threshold:
+		return teeFailed
+	case settled && outcome.failed < threshold:
+		return teeSucceeded
+	default:
+		return teeUnknown
+	}
+}
+
+// report call the callback matching the global outcome of a block
+func (t *TeeDrain) report(c cid.Cid, outcome int) {
+	switch {
+	case outcome == teeFailed && t.onFailure != nil:
+		t.onFailure(c)
+	case outcome == teeSucceeded && t.onSuccess != nil:
+		t.onSuccess(c)
+	}
+}
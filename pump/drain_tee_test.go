@@ -0,0 +1,115 @@
+package pump
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTeeDrain(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+
+	run := func(policy string, drains ...Drain) (uint, []uint) {
+		var dests []TeeDestination
+		for _, d := range drains {
+			dests = append(dests, TeeDestination{Name: "dest", Drain: d, Failed: NewNullableFileEnumeratorWriter()})
+		}
+
+		tee, err := NewTeeDrain(policy, dests...)
+		require.NoError(t, err)
+
+		blocks := sync.Map{}
+		failed := NewNullableFileEnumeratorWriter()
+		PumpIt(newMockEnumerator(&blocks, 20, pref), NewMockCollector(&blocks), tee, failed, NewNullProgressWriter(), 1)
+
+		var perDest []uint
+		for _, dest := range dests {
+			perDest = append(perDest, dest.Failed.Count())
+		}
+		return failed.Count(), perDest
+	}
+
+	ok := newMockDrain()
+	failed, perDest := run(TeeFailAny, ok, newMockFailingDrain(5))
+	require.Equal(t, uint(5), failed)
+	require.Equal(t, []uint{0, 5}, perDest)
+	require.Equal(t, uint64(20), ok.Drained)
+
+	failed, perDest = run(TeeFailAll, newMockDrain(), newMockFailingDrain(5))
+	require.Equal(t, uint(0), failed)
+	require.Equal(t, []uint{0, 5}, perDest)
+
+	failed, perDest = run(TeeFailAll, newMockFailingDrain(5), newMockFailingDrain(8))
+	require.Equal(t, uint(5), failed)
+	require.Equal(t, []uint{5, 8}, perDest)
+
+	_, err := NewTeeDrain("some")
+	require.Error(t, err)
+}
+
+func TestTeeDrainForgetSettledBlocks(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+
+	for _, policy := range []string{TeeFailAny, TeeFailAll} {
+		tee, err := NewTeeDrain(policy,
+			TeeDestination{Name: "ok", Drain: newMockDrain()},
+			TeeDestination{Name: "failing", Drain: newMockFailingDrain(5)},
+		)
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			c, err := pref.Sum([]byte{byte(i)})
+			require.NoError(t, err)
+			_ = tee.Drain(Block{CID: c})
+		}
+
+		// the outcome of each block is known without waiting for a flush
+		require.Empty(t, tee.outcomes)
+	}
+}
+
+func TestTeeDrainForgetConfirmedBlocks(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+
+	for _, policy := range []string{TeeFailAny, TeeFailAll} {
+		batch := newMockBatchDrain(2)
+		tee, err := NewTeeDrain(policy,
+			TeeDestination{Name: "failing", Drain: newMockFailingDrain(5)},
+			TeeDestination{Name: "batch", Drain: batch},
+		)
+		require.NoError(t, err)
+
+		var failed, succeeded []cid.Cid
+		tee.OnFailure(func(c cid.Cid) { failed = append(failed, c) })
+		tee.OnSuccess(func(c cid.Cid) { succeeded = append(succeeded, c) })
+
+		var errs int
+		for i := 0; i < 10; i++ {
+			c, err := pref.Sum([]byte{byte(i)})
+			require.NoError(t, err)
+			if i == 9 {
+				batch.Failing[c] = true
+			}
+			if tee.Drain(Block{CID: c}) != nil {
+				errs++
+			}
+		}
+
+		// the batch destination confirmed every block, no need to wait for a flush
+		require.Empty(t, tee.outcomes)
+
+		switch policy {
+		case TeeFailAny:
+			require.Equal(t, 5, errs)
+			require.Len(t, failed, 1)
+			require.Len(t, succeeded, 4)
+		default:
+			require.Equal(t, 0, errs)
+			require.Len(t, failed, 0)
+			require.Len(t, succeeded, 10)
+		}
+	}
+}
@@ -0,0 +1,468 @@
+package pump
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+	cbornode "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	"github.com/multiformats/go-multihash"
+	"github.com/pkg/errors"
+)
+
+var _ ConfirmingDrain = &TranscodeDrain{}
+
+// TranscodeDrain re-hash the blocks with another multihash, as CIDv1, before
+// writing them to a drain. Raw blocks are re-hashed as is, dag-pb and
+// dag-cbor blocks have their links rewritten to the new CIDs first, which
+// require their children to be transcoded before them: a parent is held
+// back until all its children are written, as confirmed by the drain if it's
+// a batch drain. The old to new CID mapping is kept in memory, and written to
+// a mapping file if any.
+//
+// Identity blocks are drained as is, their CID holding their data, and map to
+// themselves. The cumulative sizes of the dag-pb links are updated with the
+// size change of the DAG under each child, as a longer CID grows every block
+// linking it: 2 bytes per link from CIDv0 to CIDv1 with the same hash, more
+// with a longer digest.
+type TranscodeDrain struct {
+	drain  Drain
+	prefix cid.Prefix
+
+	mu        sync.Mutex
+	mapped    map[cid.Cid]transcodedBlock
+	tentative map[cid.Cid][]tentativeBlock
+	waiting   map[cid.Cid][]*pendingTranscode
+	pending   map[cid.Cid]*pendingTranscode
+	mapping   *bufio.Writer
+	mapError  error
+
+	// drained count the blocks sent to the drain, to flush it until no
+	// confirmed block release more parents
+	drained uint64
+
+	onFailure func(c cid.Cid)
+	onSuccess func(c cid.Cid)
+}
+
+// pendingTranscode is a block waiting for some of its children
+type pendingTranscode struct {
+	block   Block
+	missing int
+	// failed is set when a child failed, the block can't be transcoded anymore
+	failed bool
+}
+
+// transcodedBlock is the new CID of a block, and how much the cumulative size
+// of the DAG under it changed
+type transcodedBlock struct {
+	cid    cid.Cid
+	growth int64
+}
+
+// tentativeBlock is a block sent to the drain with its new CID, not written yet
+type tentativeBlock struct {
+	source cid.Cid
+	growth int64
+}
+
+// NewTranscodeDrain create a TranscodeDrain re-hashing with the given
+// multihash type. The mapping can be nil. A batch drain must confirm the
+// blocks it writes, the parents being held until then.
+func NewTranscodeDrain(drain Drain, mhType uint64, mapping io.Writer) (*TranscodeDrain, error) {
+	prefix := cid.Prefix{Version: 1, MhType: mhType, MhLength: -1}
+	if _, err := multihash.Sum(nil, mhType, -1); err != nil {
+		return nil, errors.Wrapf(err, "transcode to %s", multihashName(mhType))
+	}
+
+	t := &TranscodeDrain{
+		drain:     drain,
+		prefix:    prefix,
+		mapped:    make(map[cid.Cid]transcodedBlock),
+		tentative: make(map[cid.Cid][]tentativeBlock),
+		waiting:   make(map[cid.Cid][]*pendingTranscode),
+		pending:   make(map[cid.Cid]*pendingTranscode),
+	}
+	if mapping != nil {
+		t.mapping = bufio.NewWriter(mapping)
+	}
+
+	if _, ok := drain.(BatchDrain); ok {
+		batch, ok := drain.(ConfirmingDrain)
+		if !ok {
+			return nil, errors.New("transcode: the batch drain doesn't confirm the blocks it writes")
+		}
+		batch.OnSuccess(t.confirm)
+		batch.OnFailure(t.refuse)
+	}
+	return t, nil
+}
+
+func (t *TranscodeDrain) OnFailure(f func(c cid.Cid)) {
+	t.onFailure = f
+}
+
+func (t *TranscodeDrain) OnSuccess(f func(c cid.Cid)) {
+	t.onSuccess = f
+}
+
+func (t *TranscodeDrain) Drain(block Block) error {
+	if isIdentity(block.CID) {
+		return t.transcode(block)
+	}
+
+	links, err := blockLinks(block)
+	if err != nil {
+		return errors.Wrap(err, "transcode")
+	}
+
+	t.mu.Lock()
+	missing := make(map[cid.Cid]bool)
+	for _, link := range links {
+		if _, ok := t.mapped[link]; !ok && !isIdentity(link) {
+			missing[link] = true
+		}
+	}
+
+	if len(missing) > 0 {
+		p := &pendingTranscode{block: block, missing: len(missing)}
+		t.pending[block.CID] = p
+		for link := range missing {
+			t.waiting[link] = append(t.waiting[link], p)
+		}
+		t.mu.Unlock()
+		return nil
+	}
+	t.mu.Unlock()
+
+	return t.transcode(block)
+}
+
+// transcode send the block with its new CID to the drain. The new CID is
+// only mapped once the block is written, the parents would otherwise link
+// to a missing block.
+func (t *TranscodeDrain) transcode(block Block) error {
+	transcoded, growth, err := t.rewrite(block)
+	if err != nil {
+		t.abandon(block.CID)
+		return errors.Wrap(err, "transcode")
+	}
+
+	// a batch drain can confirm the block before Drain returns
+	t.mu.Lock()
+	t.tentative[transcoded.CID] = append(t.tentative[transcoded.CID], tentativeBlock{source: block.CID, growth: growth})
+	t.mu.Unlock()
+	atomic.AddUint64(&t.drained, 1)
+
+	err = t.drain.Drain(transcoded)
+	if err != nil {
+		t.mu.Lock()
+		t.tentative[transcoded.CID] = removeTentative(t.tentative[transcoded.CID], block.CID)
+		if len(t.tentative[transcoded.CID]) == 0 {
+			delete(t.tentative, transcoded.CID)
+		}
+		t.mu.Unlock()
+		t.abandon(block.CID)
+		return err
+	}
+
+	if _, ok := t.drain.(BatchDrain); !ok {
+		t.confirm(transcoded.CID)
+	}
+	return nil
+}
+
+// confirm map the blocks written with the new CID, then transcode the
+// parents that were only waiting for them
+func (t *TranscodeDrain) confirm(c cid.Cid) {
+	var ready []Block
+
+	t.mu.Lock()
+	sources := t.tentative[c]
+	delete(t.tentative, c)
+	for _, source := range sources {
+		t.mapped[source.source] = transcodedBlock{cid: c, growth: source.growth}
+		if t.mapping != nil {
+			_, err := fmt.Fprintf(t.mapping, "%s %s\n", source.source, c)
+			if err != nil && t.mapError == nil {
+				t.mapError = err
+			}
+		}
+		for _, p := range t.waiting[source.source] {
+			p.missing--
+			if p.missing == 0 && !p.failed {
+				delete(t.pending, p.block.CID)
+				ready = append(ready, p.block)
+			}
+		}
+		delete(t.waiting, source.source)
+	}
+	t.mu.Unlock()
+
+	for _, source := range sources {
+		if t.onSuccess != nil {
+			t.onSuccess(source.source)
+		}
+	}
+
+	for _, parent := range ready {
+		if err := t.transcode(parent); err != nil {
+			log.Println(errors.Wrapf(err, "failed to push block %s", parent.CID))
+			t.fail(parent.CID)
+		}
+	}
+}
+
+// refuse fail the blocks the batch drain couldn't write with the new CID,
+// and their parents
+func (t *TranscodeDrain) refuse(c cid.Cid) {
+	t.mu.Lock()
+	sources := t.tentative[c]
+	delete(t.tentative, c)
+	t.mu.Unlock()
+
+	for _, source := range sources {
+		t.fail(source.source)
+		t.abandon(source.source)
+	}
+}
+
+func removeTentative(blocks []tentativeBlock, source cid.Cid) []tentativeBlock {
+	res := blocks[:0]
+	for _, block := range blocks {
+		if !block.source.Equals(source) {
+			res = append(res, block)
+		}
+	}
+	return res
+}
+
+// abandon fail the blocks waiting for a block that could not be transcoded,
+// and in turn the blocks waiting for them.
+func (t *TranscodeDrain) abandon(c cid.Cid) {
+	var failed []Block
+
+	t.mu.Lock()
+	queue := []cid.Cid{c}
+	for len(queue) > 0 {
+		child := queue[0]
+		queue = queue[1:]
+		for _, p := range t.waiting[child] {
+			if p.failed {
+				continue
+			}
+			p.failed = true
+			delete(t.pending, p.block.CID)
+			failed = append(failed, p.block)
+			queue = append(queue, p.block.CID)
+		}
+		delete(t.waiting, child)
+	}
+	t.mu.Unlock()
+
+	for _, parent := range failed {
+		log.Printf("transcode: block %s failed, as some of its children did", parent.CID)
+		t.fail(parent.CID)
+	}
+}
+
+// rewrite return the block with its links replaced and hashed with the new
+// prefix, and how much the cumulative size of the DAG under it changed
+func (t *TranscodeDrain) rewrite(block Block) (Block, int64, error) {
+	if isIdentity(block.CID) {
+		return block, 0, nil
+	}
+
+	prefix := t.prefix
+	prefix.Codec = block.CID.Type()
+
+	data := block.Data
+	var linksGrowth int64
+	var err error
+
+	switch prefix.Codec {
+	case cid.Raw:
+	case cid.DagProtobuf:
+		data, linksGrowth, err = t.rewriteDagPB(block.Data)
+	case cid.DagCBOR:
+		data, linksGrowth, err = t.rewriteDagCBOR(block.Data)
+	default:
+		return Block{}, 0, fmt.Errorf("unsupported codec %s", codecName(prefix.Codec))
+	}
+	if err != nil {
+		return Block{}, 0, err
+	}
+
+	c, err := prefix.Sum(data)
+	if err != nil {
+		return Block{}, 0, err
+	}
+
+	growth := int64(len(data)) - int64(len(block.Data)) + linksGrowth
+	return Block{CID: c, Data: data, Pin: block.Pin, Meta: block.Meta}, growth, nil
+}
+
+func (t *TranscodeDrain) rewriteDagPB(data []byte) ([]byte, int64, error) {
+	node, err := merkledag.DecodeProtobuf(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var linksGrowth int64
+	t.mu.Lock()
+	links := make([]*ipld.Link, len(node.Links()))
+	for i, link := range node.Links() {
+		mapped := t.transcoded(link.Cid)
+		links[i] = &ipld.Link{Name: link.Name, Size: uint64(int64(link.Size) + mapped.growth), Cid: mapped.cid}
+		linksGrowth += mapped.growth
+	}
+	t.mu.Unlock()
+
+	node.SetLinks(links)
+	data, err = node.EncodeProtobuf(true)
+	return data, linksGrowth, err
+}
+
+func (t *TranscodeDrain) rewriteDagCBOR(data []byte) ([]byte, int64, error) {
+	var obj interface{}
+	err := cbornode.DecodeInto(data, &obj)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var linksGrowth int64
+	t.mu.Lock()
+	obj = mapCBORLinks(obj, func(c cid.Cid) cid.Cid {
+		mapped := t.transcoded(c)
+		linksGrowth += mapped.growth
+		return mapped.cid
+	})
+	t.mu.Unlock()
+
+	data, err = cbornode.DumpObject(obj)
+	return data, linksGrowth, err
+}
+
+// transcoded return the transcoded CID of a link, unchanged for the identity
+// CIDs, t.mu must be held
+func (t *TranscodeDrain) transcoded(c cid.Cid) transcodedBlock {
+	if mapped, ok := t.mapped[c]; ok {
+		return mapped
+	}
+	return transcodedBlock{cid: c}
+}
+
+func (t *TranscodeDrain) fail(c cid.Cid) {
+	if t.onFailure != nil {
+		t.onFailure(c)
+	}
+}
+
+// Flush the drain, until the blocks it confirms don't release parents
+// anymore, then fail the blocks still waiting for some children, which
+// never came, and flush the mapping.
+func (t *TranscodeDrain) Flush() error {
+	if batch, ok := t.drain.(BatchDrain); ok {
+		for {
+			drained := atomic.LoadUint64(&t.drained)
+			if err := batch.Flush(); err != nil {
+				return err
+			}
+			if atomic.LoadUint64(&t.drained) == drained {
+				break
+			}
+		}
+	}
+
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[cid.Cid]*pendingTranscode)
+	t.waiting = make(map[cid.Cid][]*pendingTranscode)
+	t.mu.Unlock()
+
+	for c, p := range pending {
+		log.Printf("transcode: block %s is missing %d children, they are needed to rewrite its links", c, p.missing)
+		t.fail(c)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.mapping == nil {
+		return nil
+	}
+	if t.mapError != nil {
+		return errors.Wrap(t.mapError, "transcode mapping")
+	}
+	return errors.Wrap(t.mapping.Flush(), "transcode mapping")
+}
+
+// Mapping return the new CID of a block, if it was transcoded
+func (t *TranscodeDrain) Mapping(c cid.Cid) (cid.Cid, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	mapped, ok := t.mapped[c]
+	return mapped.cid, ok
+}
+
+// blockLinks return the CIDs linked by a block
+func blockLinks(block Block) ([]cid.Cid, error) {
+	switch block.CID.Type() {
+	case cid.DagProtobuf:
+		node, err := merkledag.DecodeProtobuf(block.Data)
+		if err != nil {
+			return nil, err
+		}
+		var links []cid.Cid
+		for _, link := range node.Links() {
+			links = append(links, link.Cid)
+		}
+		return links, nil
+
+	case cid.DagCBOR:
+		var obj interface{}
+		err := cbornode.DecodeInto(block.Data, &obj)
+		if err != nil {
+			return nil, err
+		}
+		var links []cid.Cid
+		mapCBORLinks(obj, func(c cid.Cid) cid.Cid {
+			links = append(links, c)
+			return c
+		})
+		return links, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+// mapCBORLinks replace the links of a decoded dag-cbor object
+func mapCBORLinks(obj interface{}, f func(c cid.Cid) cid.Cid) interface{} {
+	switch obj := obj.(type) {
+	case cid.Cid:
+		return f(obj)
+	case map[string]interface{}:
+		for k, v := range obj {
+			obj[k] = mapCBORLinks(v, f)
+		}
+	case map[interface{}]interface{}:
+		for k, v := range obj {
+			obj[k] = mapCBORLinks(v, f)
+		}
+	case []interface{}:
+		for i, v := range obj {
+			obj[i] = mapCBORLinks(v, f)
+		}
+	}
+	return obj
+}
+
+func isIdentity(c cid.Cid) bool {
+	return c.Prefix().MhType == multihash.IDENTITY
+}
@@ -0,0 +1,305 @@
+package pump
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	cbornode "github.com/ipfs/go-ipld-cbor"
+	ipld "github.com/ipfs/go-ipld-format"
+	"github.com/ipfs/go-merkledag"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingDrain struct {
+	mu     sync.Mutex
+	blocks map[cid.Cid][]byte
+	// refused is the data of a block to fail, if any
+	refused []byte
+}
+
+func (r *recordingDrain) Drain(block Block) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.refused != nil && bytes.Equal(block.Data, r.refused) {
+		return fmt.Errorf("refused block")
+	}
+	r.blocks[block.CID] = block.Data
+	return nil
+}
+
+func TestTranscodeDrain(t *testing.T) {
+	// raw leaves, a dag-pb node linking them and a dag-cbor root linking the node
+	var blocks []Block
+	node := merkledag.NodeWithData([]byte("directory"))
+	for i := 0; i < 3; i++ {
+		data := []byte(fmt.Sprintf("leaf %d", i))
+		c, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}.Sum(data)
+		require.NoError(t, err)
+		blocks = append(blocks, Block{CID: c, Data: data})
+		require.NoError(t, node.AddRawLink(fmt.Sprintf("leaf%d", i), &ipld.Link{Cid: c, Size: uint64(len(data))}))
+	}
+	blocks = append(blocks, Block{CID: node.Cid(), Data: node.RawData()})
+
+	root, err := cbornode.WrapObject(map[string]interface{}{"dir": node.Cid(), "name": "root"}, multihash.SHA2_256, -1)
+	require.NoError(t, err)
+	blocks = append(blocks, Block{CID: root.Cid(), Data: root.RawData()})
+
+	dest := &recordingDrain{blocks: make(map[cid.Cid][]byte)}
+	var mapping strings.Builder
+	transcode, err := NewTranscodeDrain(dest, multihash.SHA2_512, &mapping)
+	require.NoError(t, err)
+
+	var failed []cid.Cid
+	transcode.OnFailure(func(c cid.Cid) {
+		failed = append(failed, c)
+	})
+
+	// parents first, they have to wait for their children
+	for i := len(blocks) - 1; i >= 0; i-- {
+		require.NoError(t, transcode.Drain(blocks[i]))
+		if i >= 3 {
+			require.Empty(t, dest.blocks)
+		}
+	}
+	require.NoError(t, transcode.Flush())
+	require.Empty(t, failed)
+	require.Len(t, dest.blocks, len(blocks))
+	require.Equal(t, len(blocks), strings.Count(mapping.String(), "\n"))
+
+	for _, block := range blocks {
+		newCID, ok := transcode.Mapping(block.CID)
+		require.True(t, ok)
+		require.Equal(t, uint64(multihash.SHA2_512), newCID.Prefix().MhType)
+		require.Equal(t, block.CID.Type(), newCID.Type())
+		require.Contains(t, dest.blocks, newCID)
+	}
+
+	// the links point to the new CIDs
+	newNode, _ := transcode.Mapping(node.Cid())
+	decoded, err := merkledag.DecodeProtobuf(dest.blocks[newNode])
+	require.NoError(t, err)
+	for i, link := range decoded.Links() {
+		newLeaf, _ := transcode.Mapping(blocks[i].CID)
+		require.Equal(t, newLeaf, link.Cid)
+	}
+
+	newRoot, _ := transcode.Mapping(root.Cid())
+	var obj map[string]interface{}
+	require.NoError(t, cbornode.DecodeInto(dest.blocks[newRoot], &obj))
+	require.Equal(t, newNode, obj["dir"])
+
+	// a parent whose children never come fails at the end
+	transcode, err = NewTranscodeDrain(dest, multihash.SHA2_512, nil)
+	require.NoError(t, err)
+	failed = nil
+	transcode.OnFailure(func(c cid.Cid) {
+		failed = append(failed, c)
+	})
+	require.NoError(t, transcode.Drain(blocks[3]))
+	require.NoError(t, transcode.Flush())
+	require.Equal(t, []cid.Cid{blocks[3].CID}, failed)
+
+	// the parents of a block refused by the destination fail with it
+	dest = &recordingDrain{blocks: make(map[cid.Cid][]byte), refused: blocks[1].Data}
+	transcode, err = NewTranscodeDrain(dest, multihash.SHA2_512, nil)
+	require.NoError(t, err)
+	failed = nil
+	transcode.OnFailure(func(c cid.Cid) {
+		failed = append(failed, c)
+	})
+	for i := len(blocks) - 1; i >= 0; i-- {
+		err := transcode.Drain(blocks[i])
+		if i == 1 {
+			require.Error(t, err)
+		} else {
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, transcode.Flush())
+	require.ElementsMatch(t, []cid.Cid{blocks[3].CID, blocks[4].CID}, failed)
+	require.Len(t, dest.blocks, 2)
+	_, ok := transcode.Mapping(blocks[1].CID)
+	require.False(t, ok)
+	_, ok = transcode.Mapping(blocks[3].CID)
+	require.False(t, ok)
+
+	_, err = NewTranscodeDrain(dest, multihash.X11, nil) // no hasher
+	require.Error(t, err)
+}
+
+func TestTranscodeDrainBlake3(t *testing.T) {
+	mhType, err := ParseMultihashType("blake3")
+	require.NoError(t, err)
+
+	dest := &recordingDrain{blocks: make(map[cid.Cid][]byte)}
+	transcode, err := NewTranscodeDrain(dest, mhType, nil)
+	require.NoError(t, err)
+
+	data := []byte("some data")
+	c, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}.Sum(data)
+	require.NoError(t, err)
+	require.NoError(t, transcode.Drain(Block{CID: c, Data: data}))
+	require.NoError(t, transcode.Flush())
+
+	newCID, ok := transcode.Mapping(c)
+	require.True(t, ok)
+	decoded, err := multihash.Decode(newCID.Hash())
+	require.NoError(t, err)
+	require.Equal(t, uint64(multihash.BLAKE3), decoded.Code)
+	require.Equal(t, 32, decoded.Length)
+
+	expected, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.BLAKE3, MhLength: -1}.Sum(data)
+	require.NoError(t, err)
+	require.Equal(t, expected, newCID)
+	require.Equal(t, data, dest.blocks[newCID])
+}
+
+func TestTranscodeDrainCIDv0Sizes(t *testing.T) {
+	// a CIDv0 dag-pb tree, each link holding the cumulative size of its child
+	mid := merkledag.NodeWithData([]byte("directory"))
+	var blocks []Block
+	for i := 0; i < 3; i++ {
+		leaf := merkledag.NodeWithData([]byte(fmt.Sprintf("leaf %d", i)))
+		blocks = append(blocks, Block{CID: leaf.Cid(), Data: leaf.RawData()})
+		require.NoError(t, mid.AddNodeLink(fmt.Sprintf("leaf%d", i), leaf))
+	}
+	root := merkledag.NodeWithData([]byte("root"))
+	require.NoError(t, root.AddNodeLink("dir", mid))
+	blocks = append(blocks, Block{CID: mid.Cid(), Data: mid.RawData()}, Block{CID: root.Cid(), Data: root.RawData()})
+	require.Equal(t, uint64(0), root.Cid().Version())
+
+	for _, mhType := range []uint64{multihash.SHA2_256, multihash.SHA2_512} {
+		dest := &recordingDrain{blocks: make(map[cid.Cid][]byte)}
+		transcode, err := NewTranscodeDrain(dest, mhType, nil)
+		require.NoError(t, err)
+
+		for _, block := range blocks {
+			require.NoError(t, transcode.Drain(block))
+		}
+		require.NoError(t, transcode.Flush())
+		require.Len(t, dest.blocks, len(blocks))
+
+		// the link sizes match the cumulative sizes of the new children
+		for c, data := range dest.blocks {
+			node, err := merkledag.DecodeProtobuf(data)
+			require.NoError(t, err)
+			for _, link := range node.Links() {
+				child, err := merkledag.DecodeProtobuf(dest.blocks[link.Cid])
+				require.NoError(t, err)
+				size, err := child.Size()
+				require.NoError(t, err)
+				require.Equal(t, size, link.Size, "link %s of %s", link.Name, c)
+			}
+		}
+
+		if mhType == multihash.SHA2_256 {
+			// CIDv1 are 2 bytes longer than CIDv0 with the same hash
+			newMid, _ := transcode.Mapping(mid.Cid())
+			require.Len(t, dest.blocks[newMid], len(mid.RawData())+3*2)
+		}
+	}
+}
+
+func TestTranscodeDrainBatch(t *testing.T) {
+	// a dag-pb node linking raw leaves
+	var leaves []Block
+	node := merkledag.NodeWithData([]byte("directory"))
+	for i := 0; i < 4; i++ {
+		data := []byte(fmt.Sprintf("leaf %d", i))
+		c, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}.Sum(data)
+		require.NoError(t, err)
+		leaves = append(leaves, Block{CID: c, Data: data})
+		require.NoError(t, node.AddRawLink(fmt.Sprintf("leaf%d", i), &ipld.Link{Cid: c, Size: uint64(len(data))}))
+	}
+	parent := Block{CID: node.Cid(), Data: node.RawData()}
+
+	for _, refuse := range []bool{false, true} {
+		dest := newMockBatchDrain(3)
+		transcode, err := NewTranscodeDrain(dest, multihash.SHA2_512, nil)
+		require.NoError(t, err)
+
+		var failed, succeeded []cid.Cid
+		transcode.OnFailure(func(c cid.Cid) { failed = append(failed, c) })
+		transcode.OnSuccess(func(c cid.Cid) { succeeded = append(succeeded, c) })
+
+		if refuse {
+			refused, _, err := transcode.rewrite(leaves[3])
+			require.NoError(t, err)
+			dest.Failing[refused.CID] = true
+		}
+
+		for _, leaf := range leaves {
+			require.NoError(t, transcode.Drain(leaf))
+		}
+		require.NoError(t, transcode.Drain(parent))
+
+		// the last leaf is only queued, the parent waits for it
+		_, ok := transcode.Mapping(leaves[3].CID)
+		require.False(t, ok)
+		require.Len(t, dest.Written, 3)
+
+		require.NoError(t, transcode.Flush())
+
+		if refuse {
+			// reported with the source CIDs, the parent failing with its child
+			require.Equal(t, []cid.Cid{leaves[3].CID, parent.CID}, failed)
+			require.Len(t, succeeded, 3)
+			require.Len(t, dest.Written, 3)
+			_, ok = transcode.Mapping(parent.CID)
+			require.False(t, ok)
+		} else {
+			require.Empty(t, failed)
+			require.Len(t, succeeded, 5)
+			require.Equal(t, parent.CID, succeeded[4])
+			require.Len(t, dest.Written, 5)
+			_, ok = transcode.Mapping(parent.CID)
+			require.True(t, ok)
+		}
+		require.Empty(t, transcode.tentative)
+	}
+
+	// the failures of a batch drain not confirming its writes can't be traced back
+	tee, err := NewTeeDrain(TeeFailAny, TeeDestination{Name: "batch", Drain: newMockBatchDrain(1)})
+	require.NoError(t, err)
+	_, err = NewTranscodeDrain(unconfirmedDrain{tee}, multihash.SHA2_512, nil)
+	require.Error(t, err)
+}
+
+// unconfirmedDrain hide the confirmations of a batch drain
+type unconfirmedDrain struct {
+	BatchDrain
+}
+
+func TestTranscodeDrainIdentity(t *testing.T) {
+	data := []byte("inline")
+	inline, err := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.IDENTITY, MhLength: -1}.Sum(data)
+	require.NoError(t, err)
+
+	node := merkledag.NodeWithData([]byte("directory"))
+	require.NoError(t, node.AddRawLink("inline", &ipld.Link{Cid: inline, Size: uint64(len(data))}))
+
+	dest := &recordingDrain{blocks: make(map[cid.Cid][]byte)}
+	transcode, err := NewTranscodeDrain(dest, multihash.SHA2_512, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, transcode.Drain(Block{CID: node.Cid(), Data: node.RawData()}))
+	require.NoError(t, transcode.Drain(Block{CID: inline, Data: data}))
+	require.NoError(t, transcode.Flush())
+
+	// kept as is, and still linked as is
+	mapped, ok := transcode.Mapping(inline)
+	require.True(t, ok)
+	require.Equal(t, inline, mapped)
+	require.Equal(t, data, dest.blocks[inline])
+
+	newNode, ok := transcode.Mapping(node.Cid())
+	require.True(t, ok)
+	decoded, err := merkledag.DecodeProtobuf(dest.blocks[newNode])
+	require.NoError(t, err)
+	require.Equal(t, inline, decoded.Links()[0].Cid)
+}
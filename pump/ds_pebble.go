@@ -0,0 +1,128 @@
+package pump
+
+import (
+	"github.com/cockroachdb/pebble"
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+)
+
+var _ ds.Datastore = &pebbleDatastore{}
+
+// pebbleDatastore is a minimal go-datastore wrapper around a Pebble database,
+// storing keys and values the same way go-ds-pebble does so that repos written
+// by IPFS nodes can be read directly.
+type pebbleDatastore struct {
+	db *pebble.DB
+}
+
+func newPebbleDatastore(path string) (*pebbleDatastore, error) {
+	db, err := pebble.Open(path, &pebble.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pebbleDatastore{db: db}, nil
+}
+
+func (p *pebbleDatastore) Get(key ds.Key) ([]byte, error) {
+	val, closer, err := p.db.Get(key.Bytes())
+	if err == pebble.ErrNotFound {
+		return nil, ds.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	// the returned slice is only valid until the closer is called
+	out := make([]byte, len(val))
+	copy(out, val)
+	return out, nil
+}
+
+func (p *pebbleDatastore) Has(key ds.Key) (bool, error) {
+	_, closer, err := p.db.Get(key.Bytes())
+	if err == pebble.ErrNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, closer.Close()
+}
+
+func (p *pebbleDatastore) GetSize(key ds.Key) (int, error) {
+	val, closer, err := p.db.Get(key.Bytes())
+	if err == pebble.ErrNotFound {
+		return -1, ds.ErrNotFound
+	}
+	if err != nil {
+		return -1, err
+	}
+	size := len(val)
+	return size, closer.Close()
+}
+
+// Writes are not synced, the database has to be closed to persist the last ones
+func (p *pebbleDatastore) Put(key ds.Key, value []byte) error {
+	return p.db.Set(key.Bytes(), value, pebble.NoSync)
+}
+
+func (p *pebbleDatastore) Delete(key ds.Key) error {
+	return p.db.Delete(key.Bytes(), pebble.NoSync)
+}
+
+func (p *pebbleDatastore) Sync(ds.Key) error {
+	return p.db.Flush()
+}
+
+func (p *pebbleDatastore) Close() error {
+	return p.db.Close()
+}
+
+func (p *pebbleDatastore) Query(q dsq.Query) (dsq.Results, error) {
+	opts := &pebble.IterOptions{}
+
+	prefix := ds.NewKey(q.Prefix).String()
+	if prefix != "/" {
+		opts.LowerBound = []byte(prefix + "/")
+		opts.UpperBound = []byte(prefix + "0") // '0' is the byte right after '/'
+	}
+
+	iter, err := p.db.NewIter(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	valid := iter.First()
+	failed := false
+
+	res := dsq.ResultsFromIterator(q, dsq.Iterator{
+		Next: func() (dsq.Result, bool) {
+			if !valid {
+				if err := iter.Error(); err != nil && !failed {
+					failed = true
+					return dsq.Result{Error: err}, true
+				}
+				return dsq.Result{}, false
+			}
+
+			entry := dsq.Entry{Key: string(iter.Key())}
+			if !q.KeysOnly {
+				entry.Value = append([]byte(nil), iter.Value()...)
+			}
+			if q.ReturnsSizes {
+				entry.Size = len(iter.Value())
+			}
+
+			valid = iter.Next()
+			return dsq.Result{Entry: entry}, true
+		},
+		Close: iter.Close,
+	})
+
+	// Prefix is already handled by the iterator bounds
+	naive := q
+	naive.Prefix = ""
+	return dsq.NaiveQueryApply(naive, res), nil
+}
@@ -0,0 +1,84 @@
+package pump
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPebbleRoundTrip(t *testing.T) {
+	path := t.TempDir()
+
+	testDatastoreRoundTrip(t,
+		func() (*DatastoreDrain, error) { return NewPebbleDrain(path) },
+		func() (*DatastoreEnumerator, error) { return NewPebbleEnumerator(path) },
+		func() (*DatastoreCollector, error) { return NewPebbleCollector(path) },
+	)
+}
+
+func TestLevelDBRoundTrip(t *testing.T) {
+	path := t.TempDir()
+
+	testDatastoreRoundTrip(t,
+		func() (*DatastoreDrain, error) { return NewLevelDBDrain(path) },
+		func() (*DatastoreEnumerator, error) { return NewLevelDBEnumerator(path) },
+		func() (*DatastoreCollector, error) { return NewLevelDBCollector(path) },
+	)
+}
+
+// testDatastoreRoundTrip pump blocks into a datastore, close it, then read
+// them back with the enumerator and the collector of the same backend.
+func testDatastoreRoundTrip(t *testing.T,
+	newDrain func() (*DatastoreDrain, error),
+	newEnumerator func() (*DatastoreEnumerator, error),
+	newCollector func() (*DatastoreCollector, error)) {
+
+	drain, err := newDrain()
+	require.NoError(t, err)
+
+	blocks := sync.Map{}
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+	countedDrain := NewCountedDrain(drain)
+	PumpIt(newMockEnumerator(&blocks, 20, pref), NewMockCollector(&blocks), countedDrain,
+		NewNullableFileEnumeratorWriter(), NewNullProgressWriter(), 2)
+	require.Equal(t, uint64(20), countedDrain.SuccessfulBlocksCount())
+	require.NoError(t, drain.Datastore().Close())
+
+	enum, err := newEnumerator()
+	require.NoError(t, err)
+	ch := make(chan BlockInfo)
+	require.NoError(t, enum.CIDs(ch))
+
+	var infos []BlockInfo
+	for info := range ch {
+		require.NoError(t, info.Error)
+		_, ok := blocks.Load(info.CID.String())
+		require.True(t, ok)
+		infos = append(infos, info)
+	}
+	require.Len(t, infos, 20)
+	require.NoError(t, enum.Datastore().Close())
+
+	coll, err := newCollector()
+	require.NoError(t, err)
+	defer coll.dstore.Close()
+
+	in := make(chan BlockInfo, len(infos))
+	for _, info := range infos {
+		in <- info
+	}
+	close(in)
+
+	out := make(chan Block)
+	require.NoError(t, coll.Blocks(in, out))
+
+	for block := range out {
+		require.NoError(t, block.Error)
+		data, ok := blocks.Load(block.CID.String())
+		require.True(t, ok)
+		require.Equal(t, data, block.Data)
+	}
+}
@@ -0,0 +1,292 @@
+package pump
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/mount"
+	"github.com/ipfs/go-datastore/namespace"
+	badger "github.com/ipfs/go-ds-badger"
+	"github.com/ipfs/go-ds-flatfs"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/pkg/errors"
+)
+
+// blocksPrefix is the key prefix under which an IPFS node stores its blocks
+const blocksPrefix = "/blocks"
+
+const (
+	repoConfigFile   = "config"
+	repoDiskSpecFile = "datastore_spec"
+)
+
+type repoConfig struct {
+	Datastore struct {
+		Spec map[string]interface{}
+	}
+}
+
+// openRepoBlockstore reads the config of an IPFS repo and open the datastore
+// holding its blocks, following the same datastore spec as the node would.
+func openRepoBlockstore(repoPath string) (ds.Datastore, error) {
+	spec, err := readRepoSpec(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return openRepoDatastore(repoPath, spec, blocksPrefix)
+}
+
+// OpenRepoDatastore reads the config of an IPFS repo and open its complete
+// datastore, with every mount, as the node would.
+func OpenRepoDatastore(repoPath string) (ds.Datastore, error) {
+	spec, err := readRepoSpec(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return openRepoRoot(repoPath, spec)
+}
+
+func readRepoSpec(repoPath string) (map[string]interface{}, error) {
+	raw, err := ioutil.ReadFile(filepath.Join(repoPath, repoConfigFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read the repo config")
+	}
+
+	var config repoConfig
+	err = json.Unmarshal(raw, &config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse the repo config")
+	}
+
+	spec := config.Datastore.Spec
+	if spec == nil {
+		return nil, fmt.Errorf("no datastore spec in the repo config")
+	}
+
+	err = checkRepoDiskSpec(repoPath, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return spec, nil
+}
+
+// checkRepoDiskSpec verify that the datastore_spec written on disk match the
+// config, as the node would refuse to start otherwise.
+func checkRepoDiskSpec(repoPath string, spec map[string]interface{}) error {
+	onDisk, err := ioutil.ReadFile(filepath.Join(repoPath, repoDiskSpecFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to read the datastore spec")
+	}
+
+	diskSpec, err := repoDiskSpec(spec)
+	if err != nil {
+		return err
+	}
+
+	// round-trip through JSON so that both sides are made of the same types
+	expected, err := json.Marshal(diskSpec)
+	if err != nil {
+		return err
+	}
+
+	var actual, wanted interface{}
+	err = json.Unmarshal(onDisk, &actual)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse the datastore spec")
+	}
+	_ = json.Unmarshal(expected, &wanted)
+
+	if !reflect.DeepEqual(actual, wanted) {
+		return fmt.Errorf("datastore_spec doesn't match the repo config: on disk %s, from config %s",
+			bytes.TrimSpace(onDisk), expected)
+	}
+
+	return nil
+}
+
+// repoDiskSpec compute the subset of a datastore spec that describe the data on disk
+func repoDiskSpec(spec map[string]interface{}) (map[string]interface{}, error) {
+	switch specType(spec) {
+	case "mount":
+		mounts, err := specMounts(spec)
+		if err != nil {
+			return nil, err
+		}
+		// same ordering as the node uses
+		sort.Slice(mounts, func(i, j int) bool {
+			return mountpoint(mounts[i]).String() > mountpoint(mounts[j]).String()
+		})
+		var diskMounts []interface{}
+		for _, mount := range mounts {
+			child, err := repoDiskSpec(mount)
+			if err != nil {
+				return nil, err
+			}
+			child["mountpoint"] = mount["mountpoint"]
+			diskMounts = append(diskMounts, child)
+		}
+		return map[string]interface{}{"type": "mount", "mounts": diskMounts}, nil
+
+	case "measure", "log":
+		child, err := specChild(spec)
+		if err != nil {
+			return nil, err
+		}
+		return repoDiskSpec(child)
+
+	case "flatfs":
+		return map[string]interface{}{"type": "flatfs", "path": spec["path"], "shardFunc": spec["shardFunc"]}, nil
+
+	case "levelds", "badgerds", "pebbleds":
+		return map[string]interface{}{"type": spec["type"], "path": spec["path"]}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported datastore type %q", specType(spec))
+	}
+}
+
+// openRepoDatastore open the datastore described by the spec, restricted to
+// the keys under prefix.
+func openRepoDatastore(repoPath string, spec map[string]interface{}, prefix string) (ds.Datastore, error) {
+	switch specType(spec) {
+	case "mount":
+		mounts, err := specMounts(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		// Pick the most specific mount that holds the prefix
+		sort.Slice(mounts, func(i, j int) bool {
+			return len(mountpoint(mounts[i]).String()) > len(mountpoint(mounts[j]).String())
+		})
+		for _, mount := range mounts {
+			mp := mountpoint(mount)
+			if mp.IsAncestorOf(ds.NewKey(prefix)) || mp.Equal(ds.NewKey(prefix)) {
+				rest := strings.TrimPrefix(ds.NewKey(prefix).String(), mp.String())
+				return openRepoDatastore(repoPath, mount, rest)
+			}
+		}
+		return nil, fmt.Errorf("no mount in the datastore spec for %s", prefix)
+
+	case "measure", "log":
+		child, err := specChild(spec)
+		if err != nil {
+			return nil, err
+		}
+		return openRepoDatastore(repoPath, child, prefix)
+	}
+
+	dstore, err := openRepoLeaf(repoPath, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if prefix != "" && prefix != "/" {
+		return namespace.Wrap(dstore, ds.NewKey(prefix)), nil
+	}
+
+	return dstore, nil
+}
+
+// openRepoRoot open the datastore described by the spec, with all its mounts
+func openRepoRoot(repoPath string, spec map[string]interface{}) (ds.Datastore, error) {
+	switch specType(spec) {
+	case "mount":
+		specs, err := specMounts(spec)
+		if err != nil {
+			return nil, err
+		}
+
+		mounts := make([]mount.Mount, 0, len(specs))
+		for _, m := range specs {
+			dstore, err := openRepoRoot(repoPath, m)
+			if err != nil {
+				return nil, err
+			}
+			mounts = append(mounts, mount.Mount{Prefix: mountpoint(m), Datastore: dstore})
+		}
+		return mount.New(mounts), nil
+
+	case "measure", "log":
+		child, err := specChild(spec)
+		if err != nil {
+			return nil, err
+		}
+		return openRepoRoot(repoPath, child)
+	}
+
+	return openRepoLeaf(repoPath, spec)
+}
+
+// openRepoLeaf open a concrete datastore (as opposed to a wrapper) of the spec
+func openRepoLeaf(repoPath string, spec map[string]interface{}) (ds.Datastore, error) {
+	path, ok := spec["path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("missing path for datastore type %q", specType(spec))
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(repoPath, path)
+	}
+
+	switch specType(spec) {
+	case "flatfs":
+		return flatfs.Open(path, false)
+	case "levelds":
+		return leveldb.NewDatastore(path, nil)
+	case "badgerds":
+		return badger.NewDatastore(path, nil)
+	case "pebbleds":
+		return newPebbleDatastore(path)
+	default:
+		return nil, fmt.Errorf("unsupported datastore type %q", specType(spec))
+	}
+}
+
+func specType(spec map[string]interface{}) string {
+	t, _ := spec["type"].(string)
+	return t
+}
+
+func specChild(spec map[string]interface{}) (map[string]interface{}, error) {
+	child, ok := spec["child"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("missing child for datastore type %q", specType(spec))
+	}
+	return child, nil
+}
+
+func specMounts(spec map[string]interface{}) ([]map[string]interface{}, error) {
+	raw, ok := spec["mounts"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("missing mounts in the datastore spec")
+	}
+
+	mounts := make([]map[string]interface{}, 0, len(raw))
+	for _, m := range raw {
+		mount, ok := m.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid mount in the datastore spec")
+		}
+		mounts = append(mounts, mount)
+	}
+	return mounts, nil
+}
+
+func mountpoint(mount map[string]interface{}) ds.Key {
+	mp, _ := mount["mountpoint"].(string)
+	return ds.NewKey(mp)
+}
@@ -0,0 +1,101 @@
+package pump
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ds-flatfs"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+const flatfsRepoConfig = `{"Datastore": {"Spec": {"mounts": [
+  {"child": {"path": "blocks", "shardFunc": "/repo/flatfs/shard/v1/next-to-last/2", "sync": true, "type": "flatfs"},
+   "mountpoint": "/blocks", "prefix": "flatfs.datastore", "type": "measure"},
+  {"child": {"compression": "none", "path": "datastore", "type": "levelds"},
+   "mountpoint": "/", "prefix": "leveldb.datastore", "type": "measure"}
+], "type": "mount"}}}`
+
+const flatfsRepoDiskSpec = `{"mounts":[{"mountpoint":"/blocks","path":"blocks","shardFunc":"/repo/flatfs/shard/v1/next-to-last/2","type":"flatfs"},{"mountpoint":"/","path":"datastore","type":"levelds"}],"type":"mount"}`
+
+const pebbleRepoConfig = `{"Datastore": {"Spec": {"mounts": [
+  {"child": {"path": "pebbleds", "type": "pebbleds"},
+   "mountpoint": "/", "prefix": "pebble.datastore", "type": "measure"}
+], "type": "mount"}}}`
+
+func TestRepoFlatFS(t *testing.T) {
+	repo := t.TempDir()
+	writeRepoFile(t, repo, repoConfigFile, flatfsRepoConfig)
+	writeRepoFile(t, repo, repoDiskSpecFile, flatfsRepoDiskSpec)
+
+	fs, err := flatfs.CreateOrOpen(filepath.Join(repo, "blocks"), flatfs.NextToLast(2), false)
+	require.NoError(t, err)
+	require.NoError(t, fs.Close())
+
+	testRepoRoundTrip(t, repo)
+}
+
+func TestRepoPebbleRoot(t *testing.T) {
+	repo := t.TempDir()
+	writeRepoFile(t, repo, repoConfigFile, pebbleRepoConfig)
+
+	testRepoRoundTrip(t, repo)
+
+	// blocks are stored under the /blocks prefix of the root datastore
+	dstore, err := newPebbleDatastore(filepath.Join(repo, "pebbleds"))
+	require.NoError(t, err)
+	defer dstore.Close()
+
+	enum := NewDatastoreEnumerator(dstore)
+	ch := make(chan BlockInfo)
+	require.NoError(t, enum.CIDs(ch))
+	for info := range ch {
+		require.Error(t, info.Error)
+	}
+}
+
+func TestRepoDiskSpecMismatch(t *testing.T) {
+	repo := t.TempDir()
+	writeRepoFile(t, repo, repoConfigFile, pebbleRepoConfig)
+	writeRepoFile(t, repo, repoDiskSpecFile, flatfsRepoDiskSpec)
+
+	_, err := NewRepoEnumerator(repo)
+	require.Error(t, err)
+}
+
+func testRepoRoundTrip(t *testing.T, repo string) {
+	drain, err := NewRepoDrain(repo)
+	require.NoError(t, err)
+
+	blocks := sync.Map{}
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+	mockDrain := NewCountedDrain(drain)
+	PumpIt(newMockEnumerator(&blocks, 20, pref), NewMockCollector(&blocks), mockDrain,
+		NewNullableFileEnumeratorWriter(), NewNullProgressWriter(), 2)
+	require.Equal(t, uint64(20), mockDrain.SuccessfulBlocksCount())
+	require.NoError(t, drain.dstore.Close())
+
+	enum, err := NewRepoEnumerator(repo)
+	require.NoError(t, err)
+	defer enum.dstore.Close()
+
+	ch := make(chan BlockInfo)
+	require.NoError(t, enum.CIDs(ch))
+
+	count := 0
+	for info := range ch {
+		require.NoError(t, info.Error)
+		_, ok := blocks.Load(info.CID.String())
+		require.True(t, ok)
+		count++
+	}
+	require.Equal(t, 20, count)
+}
+
+func writeRepoFile(t *testing.T, repo, name, content string) {
+	err := ioutil.WriteFile(filepath.Join(repo, name), []byte(content), 0644)
+	require.NoError(t, err)
+}
@@ -2,6 +2,8 @@ package pump
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
 
 	"github.com/ipfs/go-cid"
 	shell "github.com/ipfs/go-ipfs-api"
@@ -10,9 +12,17 @@ import (
 var _ Enumerator = &APIPinEnumerator{}
 
 type APIPinEnumerator struct {
-	URL        string
+	URL string
+
+	// Types restrict the enumeration to some pin types (PinTypeRecursive,
+	// PinTypeDirect, PinTypeIndirect). All pins are enumerated if empty.
+	Types []string
+	// Names request the pin names as well, which requires Kubo >= 0.13
+	Names bool
+
 	stream     bool
 	totalCount int
+	client     *http.Client
 }
 
 func NewAPIPinEnumerator(URL string, stream bool) *APIPinEnumerator {
@@ -23,6 +33,14 @@ func NewAPIPinEnumerator(URL string, stream bool) *APIPinEnumerator {
 	}
 }
 
+// NewAPIPinEnumeratorWithClient create an APIPinEnumerator using a custom HTTP
+// client, typically to authenticate, see APIClientConfig.
+func NewAPIPinEnumeratorWithClient(URL string, stream bool, client *http.Client) *APIPinEnumerator {
+	a := NewAPIPinEnumerator(URL, stream)
+	a.client = client
+	return a
+}
+
 func (a *APIPinEnumerator) TotalCount() int {
 	return a.totalCount
 }
@@ -35,28 +53,63 @@ func (a *APIPinEnumerator) CIDs(out chan<- BlockInfo) error {
 	}
 }
 
+// apiPin is the output of pin/ls, for both the direct and stream modes
+type apiPin struct {
+	Cid  string
+	Type string
+	Name string
+}
+
+// pinLsRequests build one pin/ls request per requested type, as the API
+// only accept a single type per request.
+func (a *APIPinEnumerator) pinLsRequests(s *shell.Shell) []*shell.RequestBuilder {
+	types := a.Types
+	if len(types) == 0 {
+		types = []string{"all"}
+	}
+
+	reqs := make([]*shell.RequestBuilder, 0, len(types))
+	for _, t := range types {
+		req := s.Request("pin/ls").Option("type", t)
+		if a.Names {
+			req = req.Option("names", true)
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs
+}
+
 func (a *APIPinEnumerator) directCIDs(out chan<- BlockInfo) error {
-	s := shell.NewShell(a.URL)
+	s := newShell(a.URL, a.client)
 
 	// Due to https://github.com/ipfs/go-ipfs/issues/6304 this can be *very* slow
 	// because the server has to build the full list before starting to output the
 	// first value :(
-	pins, err := s.Pins()
-	if err != nil {
-		return err
+	var pins []apiPin
+	for _, req := range a.pinLsRequests(s) {
+		var raw struct{ Keys map[string]apiPin }
+		err := req.Exec(context.Background(), &raw)
+		if err != nil {
+			return err
+		}
+
+		for str, info := range raw.Keys {
+			info.Cid = str
+			pins = append(pins, info)
+		}
 	}
 
 	a.totalCount = len(pins)
 
 	go func() {
-		for str := range pins {
-			c, err := cid.Parse(str)
+		for _, pin := range pins {
+			c, err := cid.Parse(pin.Cid)
 			if err != nil {
 				out <- BlockInfo{Error: err}
 				continue
 			}
 
-			out <- BlockInfo{CID: c}
+			out <- BlockInfo{CID: c, Pin: &PinInfo{Type: pin.Type, Name: pin.Name}}
 		}
 		close(out)
 	}()
@@ -65,11 +118,22 @@ func (a *APIPinEnumerator) directCIDs(out chan<- BlockInfo) error {
 }
 
 func (a *APIPinEnumerator) streamCIDs(out chan<- BlockInfo) error {
-	s := shell.NewShell(a.URL)
-
-	pinStream, err := s.PinsStream(context.Background())
-	if err != nil {
-		return err
+	s := newShell(a.URL, a.client)
+
+	var responses []*shell.Response
+	for _, req := range a.pinLsRequests(s) {
+		resp, err := req.Option("stream", true).Send(context.Background())
+		if err == nil && resp.Error != nil {
+			resp.Close()
+			err = resp.Error
+		}
+		if err != nil {
+			for _, r := range responses {
+				r.Close()
+			}
+			return err
+		}
+		responses = append(responses, resp)
 	}
 
 	// Now that we started the query we can properly count, which means we need to
@@ -77,15 +141,24 @@ func (a *APIPinEnumerator) streamCIDs(out chan<- BlockInfo) error {
 	a.totalCount = 0
 
 	go func() {
-		for pinInfo := range pinStream {
-			c, err := cid.Parse(pinInfo.Cid)
-			if err != nil {
-				out <- BlockInfo{Error: err}
-				continue
+		for _, resp := range responses {
+			dec := json.NewDecoder(resp.Output)
+			for {
+				var pin apiPin
+				if dec.Decode(&pin) != nil {
+					break
+				}
+
+				c, err := cid.Parse(pin.Cid)
+				if err != nil {
+					out <- BlockInfo{Error: err}
+					continue
+				}
+
+				out <- BlockInfo{CID: c, Pin: &PinInfo{Type: pin.Type, Name: pin.Name}}
+				a.totalCount++
 			}
-
-			out <- BlockInfo{CID: c}
-			a.totalCount++
+			resp.Close()
 		}
 		close(out)
 	}()
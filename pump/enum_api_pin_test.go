@@ -0,0 +1,76 @@
+package pump
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIPinEnumeratorTypes(t *testing.T) {
+	pins := []apiPin{
+		{Cid: "QmcbQviBDZ55DxF83rTJ7fQ9PgvbpSnhRany1FXhDD11UQ", Type: PinTypeRecursive, Name: "foo"},
+		{Cid: "QmcZixk3G7mmDBE7oR7MkMCeGQkzuaA5e4GS3y7szp5Tbx", Type: PinTypeRecursive},
+		{Cid: "Qmb3yq1VE7keU1ckMfLr3UW71gnSuz3kGE618dn1H3VYbv", Type: PinTypeDirect, Name: "bar"},
+		{Cid: "QmZtUAkrdTjSh2GbvkkHcf8Y5dqh5qvZDW1eH2SsnXjJR3", Type: PinTypeIndirect},
+		{Cid: "QmSDJ8nvXh4KmpYNGFFwwKuYQRz1ZAFfUDBCRNEnmDUQNn", Type: PinTypeIndirect},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		names := q.Get("names") == "true"
+
+		var matching []apiPin
+		for _, pin := range pins {
+			if q.Get("type") != "all" && q.Get("type") != pin.Type {
+				continue
+			}
+			if !names {
+				pin.Name = ""
+			}
+			matching = append(matching, pin)
+		}
+
+		if q.Get("stream") == "true" {
+			for _, pin := range matching {
+				_ = json.NewEncoder(w).Encode(pin)
+			}
+			return
+		}
+
+		keys := map[string]apiPin{}
+		for _, pin := range matching {
+			keys[pin.Cid] = apiPin{Type: pin.Type, Name: pin.Name}
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"Keys": keys})
+	}))
+	defer server.Close()
+
+	for _, stream := range []bool{false, true} {
+		enum := NewAPIPinEnumerator(server.URL, stream)
+		require.Len(t, collectPins(t, enum), 5)
+
+		enum = NewAPIPinEnumerator(server.URL, stream)
+		enum.Types = []string{PinTypeRecursive, PinTypeDirect}
+		enum.Names = true
+		found := collectPins(t, enum)
+		require.Len(t, found, 3)
+		require.Equal(t, 3, enum.TotalCount())
+		require.Equal(t, PinInfo{Type: PinTypeRecursive, Name: "foo"}, found["QmcbQviBDZ55DxF83rTJ7fQ9PgvbpSnhRany1FXhDD11UQ"])
+		require.Equal(t, PinInfo{Type: PinTypeDirect, Name: "bar"}, found["Qmb3yq1VE7keU1ckMfLr3UW71gnSuz3kGE618dn1H3VYbv"])
+	}
+}
+
+func collectPins(t *testing.T, enum Enumerator) map[string]PinInfo {
+	ch := make(chan BlockInfo)
+	require.NoError(t, enum.CIDs(ch))
+
+	found := map[string]PinInfo{}
+	for info := range ch {
+		require.NoError(t, info.Error)
+		found[info.CID.String()] = *info.Pin
+	}
+	return found
+}
@@ -0,0 +1,102 @@
+package pump
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+var _ Enumerator = &APIRefsEnumerator{}
+
+// APIRefsEnumerator enumerate every block stored by a running node, pinned
+// or not, by streaming refs/local from the API.
+//
+// Note: recent nodes index their blockstore by multihash, and refs/local
+// then report every block as a CIDv1 with the raw codec.
+type APIRefsEnumerator struct {
+	URL        string
+	totalCount int64
+	client     *http.Client
+}
+
+func NewAPIRefsEnumerator(URL string) *APIRefsEnumerator {
+	return NewAPIRefsEnumeratorWithClient(URL, nil)
+}
+
+// NewAPIRefsEnumeratorWithClient create an APIRefsEnumerator using a custom HTTP
+// client, typically to authenticate, see APIClientConfig.
+func NewAPIRefsEnumeratorWithClient(URL string, client *http.Client) *APIRefsEnumerator {
+	return &APIRefsEnumerator{
+		URL:        URL,
+		totalCount: -1,
+		client:     client,
+	}
+}
+
+func (a *APIRefsEnumerator) TotalCount() int {
+	return int(atomic.LoadInt64(&a.totalCount))
+}
+
+func (a *APIRefsEnumerator) CIDs(out chan<- BlockInfo) error {
+	s := newShell(a.URL, a.client)
+
+	resp, err := s.Request("refs/local").Send(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "API refs enumerator")
+	}
+	if resp.Error != nil {
+		resp.Close()
+		return errors.Wrap(resp.Error, "API refs enumerator")
+	}
+
+	// Now that we started the query we can properly count, which means we need to
+	// reset the counter to zero instead of -1 (meaning unknown).
+	atomic.StoreInt64(&a.totalCount, 0)
+
+	go func() {
+		defer func() {
+			resp.Close()
+			close(out)
+		}()
+
+		dec := json.NewDecoder(resp.Output)
+		for {
+			var ref struct {
+				Ref string
+				Err string
+			}
+			err := dec.Decode(&ref)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				// the decoder can't recover from a broken stream
+				out <- BlockInfo{Error: errors.Wrap(err, "refs/local")}
+				return
+			}
+
+			atomic.AddInt64(&a.totalCount, 1)
+
+			if ref.Err != "" {
+				out <- BlockInfo{Error: fmt.Errorf("refs/local: %s", ref.Err)}
+				continue
+			}
+
+			c, err := cid.Parse(ref.Ref)
+			if err != nil {
+				out <- BlockInfo{Error: err}
+				continue
+			}
+
+			out <- BlockInfo{CID: c}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package pump
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIRefsEnumerator(t *testing.T) {
+	refs := `{"Ref":"QmcbQviBDZ55DxF83rTJ7fQ9PgvbpSnhRany1FXhDD11UQ","Err":""}
+{"Ref":"","Err":"block not found"}
+{"Ref":"QmcZixk3G7mmDBE7oR7MkMCeGQkzuaA5e4GS3y7szp5Tbx","Err":""}
+`
+	truncated := `{"Ref":"QmZtUAkrdTjSh2`
+
+	for _, test := range []struct {
+		body   string
+		cids   int
+		errors int
+	}{
+		{body: refs, cids: 2, errors: 1},
+		{body: refs + truncated, cids: 2, errors: 2},
+	} {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.Equal(t, "/api/v0/refs/local", r.URL.Path)
+			_, _ = w.Write([]byte(test.body))
+		}))
+
+		enum := NewAPIRefsEnumerator(server.URL)
+		out := make(chan BlockInfo)
+		require.NoError(t, enum.CIDs(out))
+
+		var cids, errs int
+		for info := range out {
+			if info.Error != nil {
+				errs++
+				continue
+			}
+			cids++
+		}
+		require.Equal(t, test.cids, cids)
+		require.Equal(t, test.errors, errs)
+		require.Equal(t, 3, enum.TotalCount())
+
+		server.Close()
+	}
+}
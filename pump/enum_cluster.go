@@ -0,0 +1,83 @@
+package pump
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+var _ Enumerator = &ClusterEnumerator{}
+
+// ClusterEnumerator list the global pinset of an IPFS Cluster through its
+// REST API, along with the pin settings (name, replication, allocations).
+type ClusterEnumerator struct {
+	client     *clusterClient
+	totalCount int64
+}
+
+// NewClusterEnumerator create a ClusterEnumerator for the given REST API URL.
+// The HTTP client can be nil, or used to authenticate, see APIClientConfig.
+func NewClusterEnumerator(URL string, client *http.Client) *ClusterEnumerator {
+	return &ClusterEnumerator{
+		client:     newClusterClient(URL, client),
+		totalCount: -1,
+	}
+}
+
+func (c *ClusterEnumerator) TotalCount() int {
+	return int(atomic.LoadInt64(&c.totalCount))
+}
+
+func (c *ClusterEnumerator) CIDs(out chan<- BlockInfo) error {
+	stream, err := c.client.allocations(context.Background())
+	if err != nil {
+		return errors.Wrap(err, "cluster enumerator")
+	}
+
+	// Now that we started the query we can properly count, which means we need to
+	// reset the counter to zero instead of -1 (meaning unknown).
+	atomic.StoreInt64(&c.totalCount, 0)
+
+	go func() {
+		defer func() {
+			_ = stream.Close()
+			close(out)
+		}()
+
+		for {
+			pin, ok, err := stream.Next()
+			if err != nil {
+				out <- BlockInfo{Error: errors.Wrap(err, "enumerating cluster pins")}
+				return
+			}
+			if !ok {
+				return
+			}
+
+			atomic.AddInt64(&c.totalCount, 1)
+
+			parsed, err := cid.Parse(string(pin.CID))
+			if err != nil {
+				out <- BlockInfo{Error: err}
+				continue
+			}
+
+			out <- BlockInfo{
+				CID: parsed,
+				Pin: &PinInfo{
+					Type:           pin.Mode,
+					Name:           pin.Name,
+					Meta:           pin.Metadata,
+					ReplicationMin: pin.ReplicationFactorMin,
+					ReplicationMax: pin.ReplicationFactorMax,
+					Allocations:    pin.Allocations,
+				},
+			}
+		}
+	}()
+
+	return nil
+}
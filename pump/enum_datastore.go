@@ -1,7 +1,15 @@
 package pump
 
 import (
+	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	ds "github.com/ipfs/go-datastore"
 	dsq "github.com/ipfs/go-datastore/query"
@@ -13,53 +21,401 @@ var _ Enumerator = &DatastoreEnumerator{}
 
 type DatastoreEnumerator struct {
 	dstore ds.Datastore
+
+	// Prefixes restrict the enumeration to some parts of the datastore, each
+	// listed with its own query. Only supported by S3, which match the raw
+	// key prefix: the other datastores match whole key segments, which don't
+	// split the flat block keys.
+	Prefixes []string
+
+	// Parallel is the number of partitions listed concurrently. The partitions
+	// are the prefixes, the shard directories of a FlatFS, or for S3 the key
+	// prefixes of defaultKeyPartitions.
+	Parallel int
+
+	// PreCount run a second listing alongside the enumeration, only counting
+	// the keys, to get an exact total early.
+	PreCount bool
+
+	// flatfsPath is set for a FlatFS, to list its shard directories directly
+	flatfsPath string
+	// keyPrefixes is set when the datastore match raw key prefixes
+	keyPrefixes bool
+	shard       *Shard
+
+	// estimate return a cheap estimation of the keys in the partitions, used
+	// when listing the whole datastore
+	estimate func(partitions []string) (int64, error)
+
+	emitted         int64
+	partitionsCount int64
+	partitionsDone  int64
+	counted         int64
+	countedDone     int32
+	estimated       int64
+	estimatedDone   int32
 }
 
 func NewDatastoreEnumerator(dstore ds.Datastore) *DatastoreEnumerator {
 	return &DatastoreEnumerator{dstore: dstore}
 }
 
-func (*DatastoreEnumerator) TotalCount() int {
+// Datastore return the underlying datastore
+func (d *DatastoreEnumerator) Datastore() ds.Datastore {
+	return d.dstore
+}
+
+// TotalCount return the best known count, updated during the enumeration:
+// exact once everything is listed or pre-counted, otherwise extrapolated from
+// the partitions already listed or estimated from the datastore. Return -1
+// if nothing is known yet.
+func (d *DatastoreEnumerator) TotalCount() int {
+	count := atomic.LoadInt64(&d.partitionsCount)
+	done := atomic.LoadInt64(&d.partitionsDone)
+	emitted := atomic.LoadInt64(&d.emitted)
+
+	if count > 0 && done == count {
+		return int(emitted)
+	}
+	if atomic.LoadInt32(&d.countedDone) == 1 {
+		return int(atomic.LoadInt64(&d.counted))
+	}
+	if done > 0 {
+		return int(emitted * count / done)
+	}
+	if atomic.LoadInt32(&d.estimatedDone) == 1 {
+		estimated := atomic.LoadInt64(&d.estimated)
+		if estimated < emitted {
+			return int(emitted)
+		}
+		return int(estimated)
+	}
 	return -1
 }
 
-func (d *DatastoreEnumerator) CIDs(out chan<- BlockInfo) error {
-	// based on https://github.com/ipfs/go-ipfs-blockstore/blob/master/blockstore.go
+// Shard return an enumerator listing only the partitions belonging to the
+// shard, or false if the enumerator has no partitions to split.
+func (d *DatastoreEnumerator) Shard(shard Shard) (*DatastoreEnumerator, bool) {
+	if len(d.Prefixes) == 0 && d.flatfsPath == "" && !d.keyPrefixes {
+		return nil, false
+	}
 
-	// KeysOnly, because that would be _a lot_ of data.
-	q := dsq.Query{KeysOnly: true}
-	res, err := d.dstore.Query(q)
+	return &DatastoreEnumerator{
+		dstore:      d.dstore,
+		Prefixes:    d.Prefixes,
+		Parallel:    d.Parallel,
+		PreCount:    d.PreCount,
+		flatfsPath:  d.flatfsPath,
+		keyPrefixes: d.keyPrefixes,
+		estimate:    d.estimate,
+		shard:       &shard,
+	}, true
+}
+
+// partitions return the disjoint parts of the datastore to list
+func (d *DatastoreEnumerator) partitions() ([]string, error) {
+	var partitions []string
+
+	switch {
+	case len(d.Prefixes) > 0:
+		if !d.keyPrefixes {
+			return nil, errors.New("key prefixes are only supported by the S3 datastore")
+		}
+		if err := checkOverlappingPrefixes(d.Prefixes); err != nil {
+			return nil, err
+		}
+		partitions = d.Prefixes
+	case d.derivedPartitions():
+		partitions = defaultKeyPartitions()
+	case d.listFlatFS():
+		var err error
+		partitions, err = flatfsDirectories(d.flatfsPath)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return []string{""}, nil
+	}
+
+	if d.shard == nil {
+		return partitions, nil
+	}
+
+	var res []string
+	for i, partition := range partitions {
+		if d.shard.matchIndex(i) {
+			res = append(res, partition)
+		}
+	}
+	return res, nil
+}
+
+func (d *DatastoreEnumerator) CIDs(out chan<- BlockInfo) error {
+	partitions, err := d.partitions()
 	if err != nil {
 		return errors.Wrap(err, "datastore enumerator")
 	}
+	atomic.StoreInt64(&d.partitionsCount, int64(len(partitions)))
 
-	go func() {
-		defer func() {
-			_ = res.Close() // ensure exit (signals early exit, too)
-			close(out)
-		}()
+	if len(partitions) == 0 {
+		close(out)
+		return nil
+	}
 
-		for {
-			e, ok := res.NextSync()
-			if !ok {
-				return
-			}
-			if e.Error != nil {
-				log.Println(errors.Wrap(e.Error, "enumerating datastore"))
+	if d.PreCount {
+		go d.preCount(partitions)
+	} else if d.estimate != nil && len(d.Prefixes) == 0 {
+		go func() {
+			estimated, err := d.estimate(partitions)
+			if err != nil {
+				log.Println(errors.Wrap(err, "estimating the datastore count"))
 				return
 			}
+			atomic.StoreInt64(&d.estimated, estimated)
+			atomic.StoreInt32(&d.estimatedDone, 1)
+		}()
+	}
 
-			c, err := dshelp.DsKeyToCid(ds.RawKey(e.Key))
-			if err != nil {
-				out <- BlockInfo{Error: errors.Wrap(err, "error converting raw key")}
-				continue
-			}
+	// Open the first query now, to report early a broken datastore
+	var first dsq.Results
+	if !d.listFlatFS() {
+		first, err = d.query(partitions[0])
+		if err != nil {
+			return errors.Wrap(err, "datastore enumerator")
+		}
+	}
+
+	queue := make(chan partition, len(partitions))
+	queue <- partition{name: partitions[0], res: first}
+	for _, name := range partitions[1:] {
+		queue <- partition{name: name}
+	}
+	close(queue)
 
-			out <- BlockInfo{
-				CID: c,
+	parallel := d.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range queue {
+				d.listPartition(p, func(key string) {
+					d.emitKey(key, out)
+				})
+				atomic.AddInt64(&d.partitionsDone, 1)
 			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return nil
+}
+
+// derivedPartitions tells if the keys are split by defaultKeyPartitions, to
+// be listed concurrently or sharded without explicit prefixes
+func (d *DatastoreEnumerator) derivedPartitions() bool {
+	return d.keyPrefixes && len(d.Prefixes) == 0 && (d.shard != nil || d.Parallel > 1)
+}
+
+// partition is a part of the datastore to list, with its query if already opened
+type partition struct {
+	name string
+	res  dsq.Results
+}
+
+// listFlatFS tells if the FlatFS directories are listed instead of querying,
+// to be listed concurrently or sharded
+func (d *DatastoreEnumerator) listFlatFS() bool {
+	return d.flatfsPath != "" && len(d.Prefixes) == 0 && (d.shard != nil || d.Parallel > 1)
+}
+
+// preCount count the keys of all the partitions, one at a time
+func (d *DatastoreEnumerator) preCount(partitions []string) {
+	var counted int64
+	for _, name := range partitions {
+		d.listPartition(partition{name: name}, func(string) {
+			counted++
+		})
+	}
+	atomic.StoreInt64(&d.counted, counted)
+	atomic.StoreInt32(&d.countedDone, 1)
+}
+
+func (d *DatastoreEnumerator) listPartition(p partition, emit func(key string)) {
+	if d.listFlatFS() {
+		err := listFlatFSDirectory(filepath.Join(d.flatfsPath, p.name), emit)
+		if err != nil {
+			log.Println(errors.Wrapf(err, "enumerating FlatFS directory %s", p.name))
 		}
+		return
+	}
+
+	res := p.res
+	if res == nil {
+		var err error
+		res, err = d.query(p.name)
+		if err != nil {
+			log.Println(errors.Wrapf(err, "enumerating datastore prefix %s", p.name))
+			return
+		}
+	}
+	listResults(res, emit)
+}
+
+func (d *DatastoreEnumerator) query(prefix string) (dsq.Results, error) {
+	// based on https://github.com/ipfs/go-ipfs-blockstore/blob/master/blockstore.go
+
+	// KeysOnly, because that would be _a lot_ of data.
+	q := dsq.Query{Prefix: prefix, KeysOnly: true}
+	return d.dstore.Query(q)
+}
+
+func listResults(res dsq.Results, emit func(key string)) {
+	defer func() {
+		_ = res.Close() // ensure exit (signals early exit, too)
 	}()
 
+	for {
+		e, ok := res.NextSync()
+		if !ok {
+			return
+		}
+		if e.Error != nil {
+			log.Println(errors.Wrap(e.Error, "enumerating datastore"))
+			return
+		}
+
+		emit(e.Key)
+	}
+}
+
+func (d *DatastoreEnumerator) emitKey(key string, out chan<- BlockInfo) {
+	atomic.AddInt64(&d.emitted, 1)
+
+	c, err := dshelp.DsKeyToCid(ds.RawKey(key))
+	if err != nil {
+		out <- BlockInfo{Error: errors.Wrap(err, "error converting raw key")}
+		return
+	}
+
+	out <- BlockInfo{
+		CID: c,
+	}
+}
+
+// base32Alphabet is the alphabet of the block keys, see dshelp.NewKeyFromBinary
+const base32Alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZ234567"
+
+// commonKeyPrefixes are shared by most of the block keys: CIQ for the
+// sha2-256 multihashes and CIDv0, AFK and AFY for the raw and dag-pb or
+// dag-cbor CIDv1 of older nodes.
+var commonKeyPrefixes = []string{"CIQ", "AFK", "AFY"}
+
+// defaultKeyPartitions split the base32 block keys into disjoint prefixes
+// covering all of them. The common prefixes are split by their next
+// character and come first, so that they are spread across the shards,
+// followed by the rest of the key space.
+func defaultKeyPartitions() []string {
+	dense, sparse := splitKeyPrefix("")
+	return append(dense, sparse...)
+}
+
+func splitKeyPrefix(prefix string) (dense []string, sparse []string) {
+	for _, char := range base32Alphabet {
+		p := prefix + string(char)
+
+		common := false
+		within := false
+		for _, commonPrefix := range commonKeyPrefixes {
+			common = common || p == commonPrefix
+			within = within || strings.HasPrefix(commonPrefix, p)
+		}
+
+		switch {
+		case common:
+			for _, next := range base32Alphabet {
+				dense = append(dense, "/"+p+string(next))
+			}
+		case within:
+			d, s := splitKeyPrefix(p)
+			dense = append(dense, d...)
+			sparse = append(sparse, s...)
+		default:
+			sparse = append(sparse, "/"+p)
+		}
+	}
+	return dense, sparse
+}
+
+// checkOverlappingPrefixes make sure that no key match two prefixes, as it
+// would be listed twice
+func checkOverlappingPrefixes(prefixes []string) error {
+	sorted := make([]string, len(prefixes))
+	for i, prefix := range prefixes {
+		sorted[i] = strings.TrimPrefix(prefix, "/")
+	}
+	sort.Strings(sorted)
+
+	// a prefix sort right before the ones it overlaps
+	for i := 1; i < len(sorted); i++ {
+		if strings.HasPrefix(sorted[i], sorted[i-1]) {
+			return fmt.Errorf("overlapping key prefixes %q and %q", sorted[i-1], sorted[i])
+		}
+	}
 	return nil
 }
+
+// flatfsExtension is the extension of the files holding the values in a FlatFS
+const flatfsExtension = ".data"
+
+// flatfsDirectories return the sorted shard directories of a FlatFS
+func flatfsDirectories(path string) ([]string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// listFlatFSDirectory emit the keys of a FlatFS shard directory, the same way
+// a FlatFS query does, but only for this directory.
+func listFlatFSDirectory(dir string, emit func(key string)) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	for {
+		entries, err := f.ReadDir(1024)
+		for _, entry := range entries {
+			name := entry.Name()
+			if entry.IsDir() || !strings.HasSuffix(name, flatfsExtension) {
+				continue
+			}
+			emit("/" + strings.TrimSuffix(name, flatfsExtension))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package pump
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-ds-flatfs"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlatFSParallelEnumeration(t *testing.T) {
+	path := t.TempDir()
+	fs, err := flatfs.CreateOrOpen(path, flatfs.NextToLast(2), false)
+	require.NoError(t, err)
+
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+	expected := make(map[cid.Cid]bool)
+	for i := 0; i < 500; i++ {
+		c, err := pref.Sum([]byte(fmt.Sprintf("block %d", i)))
+		require.NoError(t, err)
+		require.NoError(t, fs.Put(dshelp.CidToDsKey(c), []byte("data")))
+		expected[c] = true
+	}
+	require.NoError(t, fs.Close())
+
+	collect := func(enumerator *DatastoreEnumerator) map[cid.Cid]int {
+		out := make(chan BlockInfo)
+		require.NoError(t, enumerator.CIDs(out))
+
+		seen := make(map[cid.Cid]int)
+		for info := range out {
+			require.NoError(t, info.Error)
+			seen[info.CID]++
+		}
+		return seen
+	}
+
+	enumerator, err := NewFlatFSEnumerator(path)
+	require.NoError(t, err)
+	enumerator.Parallel = 8
+	require.Equal(t, -1, enumerator.TotalCount())
+
+	seen := collect(enumerator)
+	require.Len(t, seen, len(expected))
+	for c, count := range seen {
+		require.True(t, expected[c])
+		require.Equal(t, 1, count)
+	}
+	require.Equal(t, len(expected), enumerator.TotalCount())
+
+	// pre-count
+	counting, err := NewFlatFSEnumerator(path)
+	require.NoError(t, err)
+	partitions, err := counting.partitions()
+	require.NoError(t, err)
+	counting.preCount(partitions)
+	require.Equal(t, len(expected), counting.TotalCount())
+
+	// the shards split the directories
+	seen = make(map[cid.Cid]int)
+	for i := 0; i < 3; i++ {
+		sharded, ok := enumerator.Shard(Shard{Index: i, Count: 3})
+		require.True(t, ok)
+		for c, count := range collect(sharded) {
+			seen[c] += count
+		}
+	}
+	require.Len(t, seen, len(expected))
+	for _, count := range seen {
+		require.Equal(t, 1, count)
+	}
+}
+
+func TestFlatFSEstimate(t *testing.T) {
+	path := t.TempDir()
+	fs, err := flatfs.CreateOrOpen(path, flatfs.NextToLast(1), false)
+	require.NoError(t, err)
+
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+	for i := 0; i < 2000; i++ {
+		c, err := pref.Sum([]byte(fmt.Sprintf("block %d", i)))
+		require.NoError(t, err)
+		require.NoError(t, fs.Put(dshelp.CidToDsKey(c), []byte("data")))
+	}
+	require.NoError(t, fs.Close())
+
+	enumerator, err := NewFlatFSEnumerator(path)
+	require.NoError(t, err)
+	partitions, err := enumerator.partitions()
+	require.NoError(t, err)
+	require.Equal(t, []string{""}, partitions, "a single query without parallelism")
+	require.False(t, enumerator.listFlatFS())
+
+	// the whole datastore is estimated from its directories
+	estimated, err := enumerator.estimate(partitions)
+	require.NoError(t, err)
+	require.InEpsilon(t, 2000, estimated, 0.2)
+
+	enumerator.Parallel = 4
+	partitions, err = enumerator.partitions()
+	require.NoError(t, err)
+	require.True(t, enumerator.listFlatFS())
+	require.Greater(t, len(partitions), flatfsEstimateSample, "only a sample is listed")
+
+	estimated, err = estimateFlatFSCount(path, partitions)
+	require.NoError(t, err)
+	require.InEpsilon(t, 2000, estimated, 0.2)
+
+	// the estimate is exact when all the directories are sampled
+	few := partitions[:3]
+	var count int64
+	for _, dir := range few {
+		require.NoError(t, listFlatFSDirectory(filepath.Join(path, dir), func(string) {
+			count++
+		}))
+	}
+	estimated, err = estimateFlatFSCount(path, few)
+	require.NoError(t, err)
+	require.Equal(t, count, estimated)
+}
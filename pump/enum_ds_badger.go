@@ -11,5 +11,19 @@ func NewBadgerEnumerator(path string) (*DatastoreEnumerator, error) {
 		return nil, errors.Wrap(err, "Badger enumerator")
 	}
 
-	return NewDatastoreEnumerator(ds), nil
+	enumerator := NewDatastoreEnumerator(ds)
+	enumerator.estimate = func(partitions []string) (int64, error) {
+		return estimateBadgerCount(ds), nil
+	}
+	return enumerator, nil
+}
+
+// estimateBadgerCount sum the key counts of the tables. It ignores what is
+// still in memory, and count the overwritten or deleted keys.
+func estimateBadgerCount(ds *badger.Datastore) int64 {
+	var count int64
+	for _, table := range ds.DB.Tables(true) {
+		count += int64(table.KeyCount)
+	}
+	return count
 }
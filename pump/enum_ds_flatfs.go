@@ -1,15 +1,55 @@
 package pump
 
 import (
+	"path/filepath"
+
 	"github.com/ipfs/go-ds-flatfs"
 	"github.com/pkg/errors"
 )
 
+// flatfsEstimateSample is how many shard directories are listed to estimate the count
+const flatfsEstimateSample = 16
+
 func NewFlatFSEnumerator(path string) (*DatastoreEnumerator, error) {
 	ds, err := flatfs.Open(path, false)
 	if err != nil {
 		return nil, errors.Wrap(err, "FlatFS enumerator")
 	}
 
-	return NewDatastoreEnumerator(ds), nil
+	enumerator := NewDatastoreEnumerator(ds)
+	enumerator.flatfsPath = path
+	enumerator.estimate = func(partitions []string) (int64, error) {
+		if len(partitions) == 1 && partitions[0] == "" {
+			// a single query, sample all the directories
+			var err error
+			partitions, err = flatfsDirectories(path)
+			if err != nil {
+				return -1, err
+			}
+		}
+		return estimateFlatFSCount(path, partitions)
+	}
+	return enumerator, nil
+}
+
+// estimateFlatFSCount list a few evenly spaced shard directories and
+// extrapolate to all of them, the keys being spread uniformly.
+func estimateFlatFSCount(path string, dirs []string) (int64, error) {
+	step := len(dirs) / flatfsEstimateSample
+	if step < 1 {
+		step = 1
+	}
+
+	var count, sampled int64
+	for i := 0; i < len(dirs); i += step {
+		err := listFlatFSDirectory(filepath.Join(path, dirs[i]), func(string) {
+			count++
+		})
+		if err != nil {
+			return -1, err
+		}
+		sampled++
+	}
+
+	return count * int64(len(dirs)) / sampled, nil
 }
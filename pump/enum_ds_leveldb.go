@@ -0,0 +1,15 @@
+package pump
+
+import (
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	"github.com/pkg/errors"
+)
+
+func NewLevelDBEnumerator(path string) (*DatastoreEnumerator, error) {
+	ds, err := leveldb.NewDatastore(path, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "LevelDB enumerator")
+	}
+
+	return NewDatastoreEnumerator(ds), nil
+}
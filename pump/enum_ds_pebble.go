@@ -0,0 +1,12 @@
+package pump
+
+import "github.com/pkg/errors"
+
+func NewPebbleEnumerator(path string) (*DatastoreEnumerator, error) {
+	ds, err := newPebbleDatastore(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "Pebble enumerator")
+	}
+
+	return NewDatastoreEnumerator(ds), nil
+}
@@ -0,0 +1,13 @@
+package pump
+
+import "github.com/pkg/errors"
+
+// NewRepoEnumerator open the blocks datastore of an IPFS repo, as described by its config
+func NewRepoEnumerator(path string) (*DatastoreEnumerator, error) {
+	ds, err := openRepoBlockstore(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "repo enumerator")
+	}
+
+	return NewDatastoreEnumerator(ds), nil
+}
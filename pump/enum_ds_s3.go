@@ -11,5 +11,7 @@ func NewS3Enumerator(config s3ds.Config) (*DatastoreEnumerator, error) {
 		return nil, errors.Wrap(err, "S3 enumerator")
 	}
 
-	return NewDatastoreEnumerator(s3), nil
+	enumerator := NewDatastoreEnumerator(s3)
+	enumerator.keyPrefixes = true
+	return enumerator, nil
 }
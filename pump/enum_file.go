@@ -2,39 +2,109 @@ package pump
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io"
+	"os"
 	"strings"
 
 	"github.com/ipfs/go-cid"
+	"github.com/klauspost/compress/zstd"
 	"github.com/pkg/errors"
 )
 
 var _ Enumerator = &FileEnumerator{}
 
 type FileEnumerator struct {
-	file  io.ReadSeeker
-	count int
+	file    io.Reader
+	options FileOptions
+	count   int
+}
+
+// StdinPath is the path to give to OpenFileEnumerator to read stdin
+const StdinPath = "-"
+
+// OpenFileEnumerator open a CID list, possibly compressed with gzip or zstd,
+// or read stdin if the path is StdinPath. Unless the list is read from stdin
+// or count is false, it's read a first time to count the entries.
+func OpenFileEnumerator(path string, count bool, options FileOptions) (*FileEnumerator, error) {
+	if err := options.validate(); err != nil {
+		return nil, errors.Wrap(err, "file enumerator")
+	}
+
+	if path == StdinPath {
+		file, err := decompress(os.Stdin)
+		if err != nil {
+			return nil, errors.Wrap(err, "file enumerator")
+		}
+		return NewStreamFileEnumerator(file, options)
+	}
+
+	total := -1
+	if count {
+		file, err := openDecompressed(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "file enumerator")
+		}
+		total, err = options.count(file)
+		_ = file.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "file enumerator")
+		}
+	}
+
+	file, err := openDecompressed(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "file enumerator")
+	}
+
+	return &FileEnumerator{
+		file:    file,
+		options: options,
+		count:   total,
+	}, nil
 }
 
 func NewFileEnumerator(file io.ReadSeeker) (*FileEnumerator, error) {
-	count := 0
+	return NewFileEnumeratorWithOptions(file, FileOptions{})
+}
+
+func NewFileEnumeratorWithOptions(file io.ReadSeeker, options FileOptions) (*FileEnumerator, error) {
+	if err := options.validate(); err != nil {
+		return nil, errors.Wrap(err, "file enumerator")
+	}
 
 	// Read the whole file a first time to count the number of entries
-	fileScanner := bufio.NewScanner(file)
-	for fileScanner.Scan() {
-		count++
+	count, err := options.count(file)
+	if err != nil {
+		return nil, errors.Wrap(err, "file enumerator")
 	}
 
 	// Rewind
-	_, err := file.Seek(0, io.SeekStart)
+	_, err = file.Seek(0, io.SeekStart)
 	if err != nil {
 		return nil, err
 	}
 
 	return &FileEnumerator{
-		file:  file,
-		count: count,
+		file:    file,
+		options: options,
+		count:   count,
+	}, nil
+}
+
+// NewStreamFileEnumerator read a CID list that can't be rewinded, without
+// counting the entries first.
+func NewStreamFileEnumerator(file io.Reader, options FileOptions) (*FileEnumerator, error) {
+	if err := options.validate(); err != nil {
+		return nil, errors.Wrap(err, "file enumerator")
+	}
+
+	return &FileEnumerator{
+		file:    file,
+		options: options,
+		count:   -1,
 	}, nil
 }
 
@@ -51,26 +121,117 @@ func (f *FileEnumerator) CIDs(out chan<- BlockInfo) error {
 			close(out)
 		}()
 
-		fileScanner := bufio.NewScanner(f.file)
-		for fileScanner.Scan() {
-			split := strings.Fields(fileScanner.Text())
+		f.options.parse(f.file, func(info BlockInfo) {
+			out <- info
+		})
+	}()
 
-			if len(split) < 1 {
-				out <- BlockInfo{Error: fmt.Errorf("unexpected line: %s", fileScanner.Text())}
-				continue
-			}
+	return nil
+}
 
-			c, err := cid.Parse(split[0])
-			if err != nil {
-				out <- BlockInfo{Error: errors.Wrap(err, "could not parse cid")}
-				continue
-			}
+// parseLines read the first whitespace separated field of each line
+func parseLines(r io.Reader, emit func(BlockInfo)) {
+	fileScanner := bufio.NewScanner(r)
+	for fileScanner.Scan() {
+		split := strings.Fields(fileScanner.Text())
 
-			out <- BlockInfo{
-				CID: c,
-			}
+		if len(split) < 1 {
+			emit(BlockInfo{Error: fmt.Errorf("unexpected line: %s", fileScanner.Text())})
+			continue
 		}
-	}()
 
-	return nil
+		c, err := cid.Parse(split[0])
+		if err != nil {
+			emit(BlockInfo{Error: errors.Wrap(err, "could not parse cid")})
+			continue
+		}
+
+		emit(BlockInfo{
+			CID: c,
+		})
+	}
+
+	// the scanner can't go further after a read error or a line too long
+	if err := fileScanner.Err(); err != nil {
+		emit(BlockInfo{Error: errors.Wrap(err, "could not read the CID list")})
+	}
+}
+
+func countLines(r io.Reader) (int, error) {
+	count := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		count++
+	}
+	if err := scanner.Err(); err != nil {
+		return count, errors.Wrap(err, "could not read the CID list")
+	}
+	return count, nil
+}
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// decompressedFile close both the decompressor and the file
+type decompressedFile struct {
+	io.Reader
+	closers []func() error
+}
+
+func (d *decompressedFile) Close() error {
+	var res error
+	for _, closer := range d.closers {
+		if err := closer(); err != nil && res == nil {
+			res = err
+		}
+	}
+	return res
+}
+
+func openDecompressed(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := decompress(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return res, nil
+}
+
+// decompress detect a gzip or zstd stream from its magic number and
+// decompress it, or return the file as is.
+func decompress(file io.ReadCloser) (io.ReadCloser, error) {
+	buffered := bufio.NewReader(file)
+	head, err := buffered.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(head, gzipMagic):
+		reader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, errors.Wrap(err, "gzip")
+		}
+		return &decompressedFile{Reader: reader, closers: []func() error{reader.Close, file.Close}}, nil
+
+	case bytes.HasPrefix(head, zstdMagic):
+		reader, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, errors.Wrap(err, "zstd")
+		}
+		return &decompressedFile{Reader: reader, closers: []func() error{
+			func() error { reader.Close(); return nil },
+			file.Close,
+		}}, nil
+
+	default:
+		return &decompressedFile{Reader: buffered, closers: []func() error{file.Close}}, nil
+	}
 }
@@ -0,0 +1,272 @@
+package pump
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+// Formats of the CID lists read by the FileEnumerator
+const (
+	// FileFormatLines is one CID per line, optionally followed by other fields
+	FileFormatLines = "lines"
+	// FileFormatJSON is JSON objects, as output by the IPFS commands with --enc=json
+	FileFormatJSON = "json"
+	// FileFormatCSV is CSV, the CID being in a configurable column
+	FileFormatCSV = "csv"
+)
+
+// FileOptions configure how a FileEnumerator parse the CID list
+type FileOptions struct {
+	// Format is one of the FileFormat constants, FileFormatLines if empty
+	Format string
+
+	// CSVColumn is the CID column, either its 1-based index or its name in
+	// the header. Default to the first column.
+	CSVColumn string
+	// CSVHeader tells that the first row name the columns. The other named
+	// columns are then kept as metadata.
+	CSVHeader bool
+}
+
+func (o FileOptions) validate() error {
+	switch o.Format {
+	case "", FileFormatLines, FileFormatJSON:
+	case FileFormatCSV:
+		if _, err := strconv.Atoi(o.CSVColumn); o.CSVColumn != "" && err != nil && !o.CSVHeader {
+			return fmt.Errorf("CSV column %q is a name, a header is required", o.CSVColumn)
+		}
+	default:
+		return fmt.Errorf("unknown file format %q", o.Format)
+	}
+	return nil
+}
+
+func (o FileOptions) parse(r io.Reader, emit func(BlockInfo)) {
+	switch o.Format {
+	case FileFormatJSON:
+		parseJSON(r, emit)
+	case FileFormatCSV:
+		o.parseCSV(r, emit)
+	default:
+		parseLines(r, emit)
+	}
+}
+
+// count return the number of entries, parsing the list if needed. The parse
+// errors are entries too, as they are emitted by the enumerator.
+func (o FileOptions) count(r io.Reader) (int, error) {
+	switch o.Format {
+	case FileFormatJSON, FileFormatCSV:
+		count := 0
+		o.parse(r, func(BlockInfo) {
+			count++
+		})
+		return count, nil
+	default:
+		return countLines(r)
+	}
+}
+
+// parseJSON read a stream of JSON objects, one per entry like the output of
+// "ipfs pin ls --stream --enc=json" or "ipfs refs --enc=json", or an object
+// holding all the entries under "Keys" like "ipfs pin ls --enc=json".
+func parseJSON(r io.Reader, emit func(BlockInfo)) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	for {
+		var obj map[string]interface{}
+		err := decoder.Decode(&obj)
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			// the decoder can't recover from a syntax error
+			emit(BlockInfo{Error: errors.Wrap(err, "could not parse JSON")})
+			return
+		}
+
+		if keys, ok := obj["Keys"].(map[string]interface{}); ok {
+			for key, fields := range keys {
+				fields, _ := fields.(map[string]interface{})
+				emit(jsonEntry(key, fields))
+			}
+			continue
+		}
+
+		var key string
+		for field, value := range obj {
+			switch strings.ToLower(field) {
+			case "cid", "ref", "key":
+				key = jsonCID(value)
+				delete(obj, field)
+			}
+		}
+
+		emit(jsonEntry(key, obj))
+	}
+}
+
+// jsonCID read a CID either as a string or as a {"/": "cid"} link
+func jsonCID(value interface{}) string {
+	switch value := value.(type) {
+	case string:
+		return value
+	case map[string]interface{}:
+		if link, ok := value["/"].(string); ok {
+			return link
+		}
+	}
+	return ""
+}
+
+func jsonEntry(key string, fields map[string]interface{}) BlockInfo {
+	for field, value := range fields {
+		if strings.EqualFold(field, "err") || strings.EqualFold(field, "error") {
+			if msg, ok := value.(string); ok && msg != "" {
+				return BlockInfo{Error: fmt.Errorf("%s: %s", key, msg)}
+			}
+		}
+	}
+
+	if key == "" {
+		return BlockInfo{Error: fmt.Errorf("no CID in JSON entry")}
+	}
+
+	c, err := cid.Parse(key)
+	if err != nil {
+		return BlockInfo{Error: errors.Wrap(err, "could not parse cid")}
+	}
+
+	info := BlockInfo{CID: c}
+	for field, value := range fields {
+		if strings.EqualFold(field, "err") || strings.EqualFold(field, "error") {
+			continue
+		}
+		switch value := value.(type) {
+		case string:
+			if value != "" {
+				setField(&info, field, value)
+			}
+		case json.Number, bool:
+			setField(&info, field, fmt.Sprint(value))
+		}
+	}
+	return info
+}
+
+func (o FileOptions) parseCSV(r io.Reader, emit func(BlockInfo)) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+
+	var header []string
+	if o.CSVHeader {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			emit(BlockInfo{Error: errors.Wrap(err, "could not parse CSV header")})
+			return
+		}
+		header = append(header, record...)
+	}
+
+	column, err := o.csvColumn(header)
+	if err != nil {
+		emit(BlockInfo{Error: err})
+		return
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			emit(BlockInfo{Error: errors.Wrap(err, "could not parse CSV")})
+			if _, ok := err.(*csv.ParseError); ok {
+				continue
+			}
+			return
+		}
+
+		if column >= len(record) {
+			emit(BlockInfo{Error: fmt.Errorf("unexpected row: %s", strings.Join(record, ","))})
+			continue
+		}
+
+		c, err := cid.Parse(strings.TrimSpace(record[column]))
+		if err != nil {
+			emit(BlockInfo{Error: errors.Wrap(err, "could not parse cid")})
+			continue
+		}
+
+		info := BlockInfo{CID: c}
+		for i, name := range header {
+			if i != column && i < len(record) && record[i] != "" {
+				setField(&info, name, record[i])
+			}
+		}
+		emit(info)
+	}
+}
+
+// csvColumn return the 0-based index of the CID column
+func (o FileOptions) csvColumn(header []string) (int, error) {
+	if o.CSVColumn == "" {
+		return 0, nil
+	}
+
+	if index, err := strconv.Atoi(o.CSVColumn); err == nil {
+		if index < 1 {
+			return 0, fmt.Errorf("invalid CSV column %d, the first one is 1", index)
+		}
+		return index - 1, nil
+	}
+
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), o.CSVColumn) {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("no CSV column %q", o.CSVColumn)
+}
+
+// setField store an extra field of an entry: the pin type and name go to the
+// pin, the size to the expected size and everything else to the metadata.
+func setField(info *BlockInfo, name string, value string) {
+	name = strings.TrimSpace(name)
+
+	switch strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(name)) {
+	case "type", "pintype":
+		if info.Pin == nil {
+			info.Pin = &PinInfo{}
+		}
+		info.Pin.Type = value
+	case "name", "pinname":
+		if info.Pin == nil {
+			info.Pin = &PinInfo{}
+		}
+		info.Pin.Name = value
+	case "size":
+		if size, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64); err == nil {
+			info.Size = size
+			return
+		}
+		fallthrough
+	default:
+		if info.Meta == nil {
+			info.Meta = make(map[string]string)
+		}
+		info.Meta[name] = value
+	}
+}
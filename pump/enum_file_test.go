@@ -2,19 +2,25 @@ package pump
 
 import (
 	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/klauspost/compress/zstd"
 	"github.com/stretchr/testify/require"
 )
 
-func TestFileEnumerator(t *testing.T) {
-	input := `QmcbQviBDZ55DxF83rTJ7fQ9PgvbpSnhRany1FXhDD11UQ
+const fileEnumeratorInput = `QmcbQviBDZ55DxF83rTJ7fQ9PgvbpSnhRany1FXhDD11UQ
 QmcZixk3G7mmDBE7oR7MkMCeGQkzuaA5e4GS3y7szp5Tbx
 Qmb3yq1VE7keU1ckMfLr3UW71gnSuz3kGE618dn1H3VYbv
 QmZtUAkrdTjSh2GbvkkHcf8Y5dqh5qvZDW1eH2SsnXjJR3 garbage garbage garbage
 QmSDJ8nvXh4KmpYNGFFwwKuYQRz1ZAFfUDBCRNEnmDUQNn`
 
-	reader := bytes.NewReader([]byte(input))
+func TestFileEnumerator(t *testing.T) {
+	reader := bytes.NewReader([]byte(fileEnumeratorInput))
 	enum, err := NewFileEnumerator(reader)
 	require.NoError(t, err)
 
@@ -32,3 +38,170 @@ QmSDJ8nvXh4KmpYNGFFwwKuYQRz1ZAFfUDBCRNEnmDUQNn`
 
 	require.Equal(t, 5, count)
 }
+
+func TestOpenFileEnumeratorCompressed(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name string, compress func(w io.Writer) io.WriteCloser) string {
+		path := filepath.Join(dir, name)
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		w := compress(f)
+		_, err = w.Write([]byte(fileEnumeratorInput))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		require.NoError(t, f.Close())
+		return path
+	}
+
+	paths := []string{
+		write("plain.txt", func(w io.Writer) io.WriteCloser {
+			return nopWriteCloser{w}
+		}),
+		write("list.gz", func(w io.Writer) io.WriteCloser {
+			return gzip.NewWriter(w)
+		}),
+		write("list.zst", func(w io.Writer) io.WriteCloser {
+			enc, err := zstd.NewWriter(w)
+			require.NoError(t, err)
+			return enc
+		}),
+	}
+
+	for _, path := range paths {
+		for _, count := range []bool{true, false} {
+			enum, err := OpenFileEnumerator(path, count, FileOptions{})
+			require.NoError(t, err)
+
+			if count {
+				require.Equal(t, 5, enum.TotalCount(), path)
+			} else {
+				require.Equal(t, -1, enum.TotalCount(), path)
+			}
+
+			ch := make(chan BlockInfo)
+			require.NoError(t, enum.CIDs(ch))
+
+			read := 0
+			for info := range ch {
+				require.NoError(t, info.Error)
+				read++
+			}
+			require.Equal(t, 5, read, path)
+		}
+	}
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func readFileEnumerator(t *testing.T, input string, options FileOptions) ([]BlockInfo, int) {
+	enum, err := NewFileEnumeratorWithOptions(strings.NewReader(input), options)
+	require.NoError(t, err)
+
+	ch := make(chan BlockInfo)
+	require.NoError(t, enum.CIDs(ch))
+
+	var res []BlockInfo
+	errs := 0
+	for info := range ch {
+		if info.Error != nil {
+			errs++
+			continue
+		}
+		res = append(res, info)
+	}
+	require.Equal(t, len(res)+errs, enum.TotalCount())
+	return res, errs
+}
+
+func TestFileEnumeratorJSON(t *testing.T) {
+	// ipfs pin ls --stream --enc=json
+	stream := `{"Cid":"QmcbQviBDZ55DxF83rTJ7fQ9PgvbpSnhRany1FXhDD11UQ","Name":"photos","Type":"recursive"}
+{"Cid":{"/":"QmcZixk3G7mmDBE7oR7MkMCeGQkzuaA5e4GS3y7szp5Tbx"},"Name":"","Type":"direct","Size":1234,"Origin":"backup"}`
+	infos, errs := readFileEnumerator(t, stream, FileOptions{Format: FileFormatJSON})
+	require.Equal(t, 0, errs)
+	require.Len(t, infos, 2)
+	require.Equal(t, "QmcbQviBDZ55DxF83rTJ7fQ9PgvbpSnhRany1FXhDD11UQ", infos[0].CID.String())
+	require.Equal(t, &PinInfo{Type: PinTypeRecursive, Name: "photos"}, infos[0].Pin)
+	require.Equal(t, "QmcZixk3G7mmDBE7oR7MkMCeGQkzuaA5e4GS3y7szp5Tbx", infos[1].CID.String())
+	require.Equal(t, &PinInfo{Type: PinTypeDirect}, infos[1].Pin)
+	require.Equal(t, uint64(1234), infos[1].Size)
+	require.Equal(t, map[string]string{"Origin": "backup"}, infos[1].Meta)
+
+	// ipfs pin ls --enc=json
+	keys := `{"Keys":{"QmcbQviBDZ55DxF83rTJ7fQ9PgvbpSnhRany1FXhDD11UQ":{"Type":"recursive"},"QmcZixk3G7mmDBE7oR7MkMCeGQkzuaA5e4GS3y7szp5Tbx":{"Type":"indirect"}}}`
+	infos, errs = readFileEnumerator(t, keys, FileOptions{Format: FileFormatJSON})
+	require.Equal(t, 0, errs)
+	require.Len(t, infos, 2)
+
+	// ipfs refs --enc=json
+	refs := `{"Ref":"QmcbQviBDZ55DxF83rTJ7fQ9PgvbpSnhRany1FXhDD11UQ","Err":""}
+{"Ref":"","Err":"merkledag: not found"}`
+	infos, errs = readFileEnumerator(t, refs, FileOptions{Format: FileFormatJSON})
+	require.Equal(t, 1, errs)
+	require.Len(t, infos, 1)
+	require.Nil(t, infos[0].Pin)
+}
+
+func TestFileEnumeratorCSV(t *testing.T) {
+	input := `name,cid,size,owner
+photos,QmcbQviBDZ55DxF83rTJ7fQ9PgvbpSnhRany1FXhDD11UQ,42,alice
+videos,QmcZixk3G7mmDBE7oR7MkMCeGQkzuaA5e4GS3y7szp5Tbx,,
+broken,not a cid,1,bob`
+
+	infos, errs := readFileEnumerator(t, input, FileOptions{Format: FileFormatCSV, CSVColumn: "cid", CSVHeader: true})
+	require.Equal(t, 1, errs)
+	require.Len(t, infos, 2)
+	require.Equal(t, "QmcbQviBDZ55DxF83rTJ7fQ9PgvbpSnhRany1FXhDD11UQ", infos[0].CID.String())
+	require.Equal(t, "photos", infos[0].Pin.Name)
+	require.Equal(t, uint64(42), infos[0].Size)
+	require.Equal(t, map[string]string{"owner": "alice"}, infos[0].Meta)
+	require.Nil(t, infos[1].Meta)
+
+	// by index, without header
+	noHeader := strings.SplitN(input, "\n", 2)[1]
+	infos, errs = readFileEnumerator(t, noHeader, FileOptions{Format: FileFormatCSV, CSVColumn: "2"})
+	require.Equal(t, 1, errs)
+	require.Len(t, infos, 2)
+	require.Nil(t, infos[0].Pin)
+
+	// a column name needs a header
+	_, err := NewFileEnumeratorWithOptions(strings.NewReader(noHeader), FileOptions{Format: FileFormatCSV, CSVColumn: "cid"})
+	require.Error(t, err)
+}
+
+func TestFileEnumeratorBrokenInput(t *testing.T) {
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, err := w.Write([]byte(fileEnumeratorInput + "\n" + strings.Repeat("Qm", 50000)))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	longLine := fileEnumeratorInput + "\n" + strings.Repeat("Qm", 50000) + "\n" + fileEnumeratorInput
+	truncated, err := gzip.NewReader(bytes.NewReader(compressed.Bytes()[:compressed.Len()/2]))
+	require.NoError(t, err)
+
+	for _, input := range []io.Reader{strings.NewReader(longLine), truncated} {
+		enum, err := NewStreamFileEnumerator(input, FileOptions{})
+		require.NoError(t, err)
+
+		ch := make(chan BlockInfo)
+		require.NoError(t, enum.CIDs(ch))
+
+		// the read error end the list
+		var last BlockInfo
+		for info := range ch {
+			last = info
+		}
+		require.Error(t, last.Error)
+		require.Contains(t, last.Error.Error(), "could not read the CID list")
+	}
+
+	// the entries can't be counted either
+	_, err = NewFileEnumerator(strings.NewReader(longLine))
+	require.Error(t, err)
+}
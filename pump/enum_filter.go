@@ -0,0 +1,142 @@
+package pump
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+var _ Enumerator = &FilterEnumerator{}
+
+// CIDFilter select the CIDs by codec, multihash type and version. Empty
+// lists don't filter anything.
+type CIDFilter struct {
+	Codecs        []uint64
+	ExcludeCodecs []uint64
+
+	MhTypes        []uint64
+	ExcludeMhTypes []uint64
+
+	Versions []uint64
+
+	// SkipIdentity skip the CIDs using the identity hash, as their data is
+	// in the CID itself and doesn't need to be stored.
+	SkipIdentity bool
+}
+
+// Match tells if the CID pass the filter
+func (f CIDFilter) Match(c cid.Cid) bool {
+	pref := c.Prefix()
+
+	if f.SkipIdentity && pref.MhType == multihash.IDENTITY {
+		return false
+	}
+	if len(f.Codecs) > 0 && !containsCode(f.Codecs, pref.Codec) {
+		return false
+	}
+	if containsCode(f.ExcludeCodecs, pref.Codec) {
+		return false
+	}
+	if len(f.MhTypes) > 0 && !containsCode(f.MhTypes, pref.MhType) {
+		return false
+	}
+	if containsCode(f.ExcludeMhTypes, pref.MhType) {
+		return false
+	}
+	if len(f.Versions) > 0 && !containsCode(f.Versions, pref.Version) {
+		return false
+	}
+	return true
+}
+
+func containsCode(codes []uint64, code uint64) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterEnumerator only emit the CIDs of another enumerator that pass a CIDFilter
+type FilterEnumerator struct {
+	enumerator Enumerator
+	filter     CIDFilter
+	skipped    uint64
+}
+
+func NewFilterEnumerator(enumerator Enumerator, filter CIDFilter) *FilterEnumerator {
+	return &FilterEnumerator{enumerator: enumerator, filter: filter}
+}
+
+// TotalCount return the count of the source minus the CIDs skipped so far
+func (f *FilterEnumerator) TotalCount() int {
+	total := f.enumerator.TotalCount()
+	if total < 0 {
+		return total
+	}
+	return total - int(f.Skipped())
+}
+
+// Skipped return the number of CIDs that didn't pass the filter
+func (f *FilterEnumerator) Skipped() uint64 {
+	return atomic.LoadUint64(&f.skipped)
+}
+
+func (f *FilterEnumerator) CIDs(out chan<- BlockInfo) error {
+	in := make(chan BlockInfo)
+	err := f.enumerator.CIDs(in)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for info := range in {
+			if info.Error == nil && !f.filter.Match(info.CID) {
+				atomic.AddUint64(&f.skipped, 1)
+				continue
+			}
+			out <- info
+		}
+		close(out)
+	}()
+
+	return nil
+}
+
+// codecAliases are the current names of the codecs, not known by go-cid
+var codecAliases = map[string]uint64{
+	"dag-pb":   cid.DagProtobuf,
+	"dag-cbor": cid.DagCBOR,
+	"dag-json": 0x0129,
+	"json":     0x0200,
+}
+
+// ParseCodec parse a codec name (raw, dag-pb, dag-cbor ...) or number
+func ParseCodec(name string) (uint64, error) {
+	if code, ok := codecAliases[name]; ok {
+		return code, nil
+	}
+	if code, ok := cid.Codecs[name]; ok {
+		return code, nil
+	}
+	if code, err := strconv.ParseUint(name, 0, 64); err == nil {
+		return code, nil
+	}
+	return 0, fmt.Errorf("unknown codec: %s", name)
+}
+
+// ParseMultihashType parse a multihash name (sha2-256, identity ...) or number
+func ParseMultihashType(name string) (uint64, error) {
+	if code, ok := multihash.Names[strings.ToLower(name)]; ok {
+		return code, nil
+	}
+	if code, err := strconv.ParseUint(name, 0, 64); err == nil {
+		return code, nil
+	}
+	return 0, fmt.Errorf("unknown multihash type: %s", name)
+}
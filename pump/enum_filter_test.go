@@ -0,0 +1,88 @@
+package pump
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIDFilter(t *testing.T) {
+	sum := func(pref cid.Prefix, data string) cid.Cid {
+		c, err := pref.Sum([]byte(data))
+		require.NoError(t, err)
+		return c
+	}
+
+	v0 := sum(cid.Prefix{Version: 0, Codec: cid.DagProtobuf, MhType: multihash.SHA2_256, MhLength: -1}, "v0")
+	raw := sum(cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}, "raw")
+	cbor := sum(cid.Prefix{Version: 1, Codec: cid.DagCBOR, MhType: multihash.BLAKE2B_MIN + 31, MhLength: -1}, "cbor")
+	identity := sum(cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.IDENTITY, MhLength: -1}, "identity")
+	all := []cid.Cid{v0, raw, cbor, identity}
+
+	matching := func(filter CIDFilter) []cid.Cid {
+		var res []cid.Cid
+		for _, c := range all {
+			if filter.Match(c) {
+				res = append(res, c)
+			}
+		}
+		return res
+	}
+
+	require.Equal(t, all, matching(CIDFilter{}))
+	require.Equal(t, []cid.Cid{v0, raw, cbor}, matching(CIDFilter{SkipIdentity: true}))
+	require.Equal(t, []cid.Cid{raw, identity}, matching(CIDFilter{Codecs: []uint64{cid.Raw}}))
+	require.Equal(t, []cid.Cid{v0, cbor}, matching(CIDFilter{ExcludeCodecs: []uint64{cid.Raw}}))
+	require.Equal(t, []cid.Cid{v0, raw}, matching(CIDFilter{MhTypes: []uint64{multihash.SHA2_256}}))
+	require.Equal(t, []cid.Cid{v0, raw, identity}, matching(CIDFilter{ExcludeMhTypes: []uint64{multihash.BLAKE2B_MIN + 31}}))
+	require.Equal(t, []cid.Cid{v0}, matching(CIDFilter{Versions: []uint64{0}}))
+
+	code, err := ParseCodec("dag-pb")
+	require.NoError(t, err)
+	require.Equal(t, uint64(cid.DagProtobuf), code)
+	code, err = ParseCodec("0x55")
+	require.NoError(t, err)
+	require.Equal(t, uint64(cid.Raw), code)
+	_, err = ParseCodec("nope")
+	require.Error(t, err)
+
+	code, err = ParseMultihashType("identity")
+	require.NoError(t, err)
+	require.Equal(t, uint64(multihash.IDENTITY), code)
+}
+
+func TestFilters(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+	identityPref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.IDENTITY, MhLength: -1}
+
+	blocks := &sync.Map{}
+	var list strings.Builder
+	for _, data := range []string{"a", "bb", "ccc", "dddd", "eeeee"} {
+		for _, p := range []cid.Prefix{pref, identityPref} {
+			c, err := p.Sum([]byte(data))
+			require.NoError(t, err)
+			blocks.Store(c.String(), []byte(data))
+			list.WriteString(c.String() + "\n")
+		}
+	}
+
+	file, err := NewFileEnumerator(strings.NewReader(list.String()))
+	require.NoError(t, err)
+
+	enumerator := NewFilterEnumerator(file, CIDFilter{SkipIdentity: true})
+	collector := NewSizeFilterCollector(NewMockCollector(blocks), SizeFilter{Min: 2, Max: 4})
+	drain := NewCountedDrain(newMockDrain())
+	failed := NewNullableFileEnumeratorWriter()
+
+	PumpIt(enumerator, collector, drain, failed, NewNullProgressWriter(), 2)
+
+	require.Equal(t, uint64(3), drain.SuccessfulBlocksCount())
+	require.Equal(t, uint(0), failed.Count())
+	require.Equal(t, uint64(5), enumerator.Skipped())
+	require.Equal(t, 5, enumerator.TotalCount())
+	require.Equal(t, uint64(2), collector.Skipped())
+}
@@ -0,0 +1,116 @@
+package pump
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+var _ Enumerator = &PinningServiceEnumerator{}
+
+// pinningServicePageSize is the maximum page size allowed by the spec
+const pinningServicePageSize = 1000
+
+// PinningServiceEnumerator list the pins of an IPFS Pinning Service API endpoint
+type PinningServiceEnumerator struct {
+	client     *pinningServiceClient
+	status     []string
+	name       string
+	totalCount int
+}
+
+// NewPinningServiceEnumerator create an enumerator for the pins of a Pinning Service.
+// Pins can be filtered by status (queued, pinning, pinned, failed) and by name.
+// The HTTP client can be nil, or used to configure TLS and timeouts, see APIClientConfig.
+func NewPinningServiceEnumerator(URL, token string, status []string, name string, client *http.Client) *PinningServiceEnumerator {
+	return &PinningServiceEnumerator{
+		client:     newPinningServiceClient(URL, token, client),
+		status:     status,
+		name:       name,
+		totalCount: -1,
+	}
+}
+
+func (p *PinningServiceEnumerator) TotalCount() int {
+	return p.totalCount
+}
+
+func (p *PinningServiceEnumerator) CIDs(out chan<- BlockInfo) error {
+	query := psListQuery{
+		Name:   p.name,
+		Status: p.status,
+		Limit:  pinningServicePageSize,
+	}
+
+	page, err := p.client.listPins(context.Background(), query)
+	if err != nil {
+		return errors.Wrap(err, "pinning service enumerator")
+	}
+
+	p.totalCount = page.Count
+
+	go func() {
+		defer close(out)
+
+		// The pins created at the page boundary are requested again, as
+		// "before" is strict and several pins can share that timestamp.
+		// They are recognized by their request ID.
+		var boundary time.Time
+		seen := make(map[string]bool)
+
+		for {
+			fresh := 0
+			for _, status := range page.Results {
+				if !status.Created.Equal(boundary) {
+					boundary = status.Created
+					seen = make(map[string]bool)
+				}
+				if seen[status.RequestID] {
+					continue
+				}
+				seen[status.RequestID] = true
+				fresh++
+
+				c, err := cid.Parse(status.Pin.CID)
+				if err != nil {
+					out <- BlockInfo{Error: err}
+					continue
+				}
+
+				out <- BlockInfo{
+					CID: c,
+					Pin: &PinInfo{
+						Name: status.Pin.Name,
+						Meta: status.Pin.Meta,
+					},
+				}
+			}
+
+			if len(page.Results) < pinningServicePageSize {
+				return
+			}
+
+			// Results are sorted by creation date, most recent first
+			query.Before = boundary.Add(time.Nanosecond)
+			if fresh == 0 {
+				// a whole page share the same timestamp, there is no way
+				// to page through it
+				log.Printf("pinning service enumerator: more than %d pins created at %s, some are skipped",
+					pinningServicePageSize, boundary.Format(time.RFC3339Nano))
+				query.Before = boundary
+			}
+
+			page, err = p.client.listPins(context.Background(), query)
+			if err != nil {
+				log.Println(errors.Wrap(err, "pinning service enumerator"))
+				return
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,182 @@
+package pump
+
+import (
+	"container/heap"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+)
+
+var _ Enumerator = &SampleEnumerator{}
+
+// SampleEnumerator emit a random subset of the CIDs of another enumerator,
+// either a fixed count or a percentage.
+//
+// Each CID is ranked with a hash seeded by the given seed, so that the sample
+// is reproducible: it only depends on the seed and on the set of CIDs, not on
+// the enumeration order. A fixed count is a reservoir of the lowest ranks,
+// and a percentage select the CIDs ranked below the threshold.
+type SampleEnumerator struct {
+	enumerator Enumerator
+	count      int
+	percent    float64
+	seed       uint64
+
+	population uint64
+	totalCount int64
+}
+
+// NewSampleEnumerator create a SampleEnumerator emitting count CIDs.
+func NewSampleEnumerator(enumerator Enumerator, count int, seed uint64) *SampleEnumerator {
+	return &SampleEnumerator{
+		enumerator: enumerator,
+		count:      count,
+		seed:       seed,
+		totalCount: -1,
+	}
+}
+
+// NewPercentSampleEnumerator create a SampleEnumerator emitting about percent
+// of the CIDs.
+func NewPercentSampleEnumerator(enumerator Enumerator, percent float64, seed uint64) (*SampleEnumerator, error) {
+	if percent <= 0 || percent > 100 {
+		return nil, fmt.Errorf("invalid sample percentage: %v", percent)
+	}
+	return &SampleEnumerator{
+		enumerator: enumerator,
+		percent:    percent,
+		seed:       seed,
+		totalCount: -1,
+	}, nil
+}
+
+func (s *SampleEnumerator) TotalCount() int {
+	if total := atomic.LoadInt64(&s.totalCount); total >= 0 {
+		return int(total)
+	}
+
+	if s.percent > 0 {
+		if total := s.enumerator.TotalCount(); total >= 0 {
+			return int(math.Round(float64(total) * s.percent / 100))
+		}
+		return -1
+	}
+
+	// the reservoir is only emitted once full, but we know its size if
+	// the source is large enough
+	if total := s.enumerator.TotalCount(); total >= 0 && total < s.count {
+		return total
+	}
+	return s.count
+}
+
+// Population return the number of CIDs enumerated by the source, which the
+// sample is taken from.
+func (s *SampleEnumerator) Population() uint64 {
+	return atomic.LoadUint64(&s.population)
+}
+
+func (s *SampleEnumerator) CIDs(out chan<- BlockInfo) error {
+	in := make(chan BlockInfo)
+	err := s.enumerator.CIDs(in)
+	if err != nil {
+		return err
+	}
+
+	if s.percent > 0 {
+		go s.percentSample(in, out)
+	} else {
+		go s.reservoirSample(in, out)
+	}
+
+	return nil
+}
+
+func (s *SampleEnumerator) percentSample(in <-chan BlockInfo, out chan<- BlockInfo) {
+	threshold := uint64(math.MaxUint64)
+	if s.percent < 100 {
+		threshold = uint64(s.percent / 100 * math.MaxUint64)
+	}
+
+	for info := range in {
+		if info.Error != nil {
+			out <- info
+			continue
+		}
+
+		atomic.AddUint64(&s.population, 1)
+		if s.rank(info.CID) <= threshold {
+			out <- info
+		}
+	}
+	close(out)
+}
+
+func (s *SampleEnumerator) reservoirSample(in <-chan BlockInfo, out chan<- BlockInfo) {
+	reservoir := &sampleReservoir{}
+	seen := make(map[string]bool)
+
+	for info := range in {
+		if info.Error != nil {
+			out <- info
+			continue
+		}
+
+		atomic.AddUint64(&s.population, 1)
+		if s.count <= 0 || seen[info.CID.KeyString()] {
+			continue
+		}
+
+		rank := s.rank(info.CID)
+		if reservoir.Len() == s.count && rank >= (*reservoir)[0].rank {
+			continue
+		}
+
+		heap.Push(reservoir, sampleEntry{info: info, rank: rank})
+		seen[info.CID.KeyString()] = true
+		if reservoir.Len() > s.count {
+			evicted := heap.Pop(reservoir).(sampleEntry)
+			delete(seen, evicted.info.CID.KeyString())
+		}
+	}
+
+	atomic.StoreInt64(&s.totalCount, int64(reservoir.Len()))
+	for _, entry := range *reservoir {
+		out <- entry.info
+	}
+	close(out)
+}
+
+// rank is a seeded, uniformly distributed hash of the CID
+func (s *SampleEnumerator) rank(c cid.Cid) uint64 {
+	var seed [8]byte
+	binary.BigEndian.PutUint64(seed[:], s.seed)
+
+	h := sha256.New()
+	h.Write(seed[:])
+	h.Write(c.Bytes())
+	return binary.BigEndian.Uint64(h.Sum(nil))
+}
+
+type sampleEntry struct {
+	info BlockInfo
+	rank uint64
+}
+
+// sampleReservoir is a max-heap on the rank, to evict the highest rank
+type sampleReservoir []sampleEntry
+
+func (r sampleReservoir) Len() int            { return len(r) }
+func (r sampleReservoir) Less(i, j int) bool  { return r[i].rank > r[j].rank }
+func (r sampleReservoir) Swap(i, j int)       { r[i], r[j] = r[j], r[i] }
+func (r *sampleReservoir) Push(x interface{}) { *r = append(*r, x.(sampleEntry)) }
+func (r *sampleReservoir) Pop() interface{} {
+	old := *r
+	x := old[len(old)-1]
+	*r = old[:len(old)-1]
+	return x
+}
@@ -0,0 +1,69 @@
+package pump
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampleEnumerator(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+
+	var cids []string
+	for i := 0; i < 1000; i++ {
+		c, err := pref.Sum([]byte(fmt.Sprintf("block %d", i)))
+		require.NoError(t, err)
+		cids = append(cids, c.String())
+	}
+	reversed := make([]string, len(cids))
+	for i, c := range cids {
+		reversed[len(cids)-1-i] = c
+	}
+
+	sample := func(list []string, build func(Enumerator) *SampleEnumerator) map[string]bool {
+		enumerator, err := NewFileEnumerator(strings.NewReader(strings.Join(list, "\n")))
+		require.NoError(t, err)
+
+		sampler := build(enumerator)
+		out := make(chan BlockInfo)
+		require.NoError(t, sampler.CIDs(out))
+
+		res := make(map[string]bool)
+		for info := range out {
+			require.NoError(t, info.Error)
+			res[info.CID.String()] = true
+		}
+		require.Equal(t, uint64(1000), sampler.Population())
+		return res
+	}
+
+	byCount := func(seed uint64) func(Enumerator) *SampleEnumerator {
+		return func(e Enumerator) *SampleEnumerator {
+			return NewSampleEnumerator(e, 50, seed)
+		}
+	}
+	byPercent := func(seed uint64) func(Enumerator) *SampleEnumerator {
+		return func(e Enumerator) *SampleEnumerator {
+			s, err := NewPercentSampleEnumerator(e, 10, seed)
+			require.NoError(t, err)
+			return s
+		}
+	}
+
+	first := sample(cids, byCount(1))
+	require.Len(t, first, 50)
+	require.Equal(t, first, sample(reversed, byCount(1)))
+	require.NotEqual(t, first, sample(cids, byCount(2)))
+
+	first = sample(cids, byPercent(1))
+	require.InDelta(t, 100, len(first), 40)
+	require.Equal(t, first, sample(reversed, byPercent(1)))
+	require.NotEqual(t, first, sample(cids, byPercent(2)))
+
+	_, err := NewPercentSampleEnumerator(nil, 0, 1)
+	require.Error(t, err)
+}
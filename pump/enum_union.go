@@ -0,0 +1,186 @@
+package pump
+
+import (
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ipfs/go-cid"
+	leveldb "github.com/ipfs/go-ds-leveldb"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	"github.com/pkg/errors"
+)
+
+var _ Enumerator = &UnionEnumerator{}
+
+// UnionEnumerator merge several enumerators into a single stream, removing
+// the duplicated blocks. The CIDs are compared by multihash, as the same
+// block can be listed with another CID version or codec by each source.
+type UnionEnumerator struct {
+	enumerators []Enumerator
+	seen        CIDSet
+	duplicates  uint64
+}
+
+// NewUnionEnumerator create an UnionEnumerator using the given set to
+// remember the CIDs already emitted, see NewMemoryCIDSet and NewDiskCIDSet.
+func NewUnionEnumerator(seen CIDSet, enumerators ...Enumerator) *UnionEnumerator {
+	return &UnionEnumerator{
+		enumerators: enumerators,
+		seen:        seen,
+	}
+}
+
+// TotalCount return the sum of the known counts, duplicates included.
+func (u *UnionEnumerator) TotalCount() int {
+	total := -1
+	for _, e := range u.enumerators {
+		count := e.TotalCount()
+		if count < 0 {
+			continue
+		}
+		if total < 0 {
+			total = 0
+		}
+		total += count
+	}
+	return total
+}
+
+// Duplicates return the number of CIDs skipped because already emitted
+func (u *UnionEnumerator) Duplicates() uint64 {
+	return atomic.LoadUint64(&u.duplicates)
+}
+
+func (u *UnionEnumerator) CIDs(out chan<- BlockInfo) error {
+	merged := make(chan BlockInfo)
+
+	var wg sync.WaitGroup
+	for _, e := range u.enumerators {
+		in := make(chan BlockInfo)
+		err := e.CIDs(in)
+		if err != nil {
+			return errors.Wrap(err, "union enumerator")
+		}
+
+		wg.Add(1)
+		go func() {
+			for info := range in {
+				merged <- info
+			}
+			wg.Done()
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	go func() {
+		defer func() {
+			err := u.seen.Close()
+			if err != nil {
+				log.Println(errors.Wrap(err, "union enumerator"))
+			}
+			close(out)
+		}()
+
+		for info := range merged {
+			if info.Error != nil {
+				out <- info
+				continue
+			}
+
+			added, err := u.seen.Add(info.CID)
+			if err != nil {
+				out <- BlockInfo{Error: errors.Wrapf(err, "union enumerator: deduplicating %s", info.CID)}
+				continue
+			}
+			if !added {
+				atomic.AddUint64(&u.duplicates, 1)
+				continue
+			}
+
+			out <- info
+		}
+	}()
+
+	return nil
+}
+
+// CIDSet is a set of CIDs, used to remove duplicates
+type CIDSet interface {
+	// Add insert the CID and tell if it wasn't already in the set. CIDs
+	// with the same multihash are the same entry.
+	Add(c cid.Cid) (bool, error)
+	Close() error
+}
+
+var _ CIDSet = &memoryCIDSet{}
+var _ CIDSet = &diskCIDSet{}
+
+type memoryCIDSet struct {
+	set map[string]struct{}
+}
+
+// NewMemoryCIDSet create a CIDSet held in memory
+func NewMemoryCIDSet() CIDSet {
+	return &memoryCIDSet{set: make(map[string]struct{})}
+}
+
+func (m *memoryCIDSet) Add(c cid.Cid) (bool, error) {
+	key := string(c.Hash())
+	if _, ok := m.set[key]; ok {
+		return false, nil
+	}
+	m.set[key] = struct{}{}
+	return true, nil
+}
+
+func (m *memoryCIDSet) Close() error {
+	m.set = nil
+	return nil
+}
+
+type diskCIDSet struct {
+	dir    string
+	dstore *leveldb.Datastore
+}
+
+// NewDiskCIDSet create a CIDSet stored in a temporary LevelDB database, for
+// the inputs too large to fit in memory. The database is removed on Close.
+func NewDiskCIDSet() (CIDSet, error) {
+	dir, err := os.MkdirTemp("", "ipfs-pump-union-")
+	if err != nil {
+		return nil, err
+	}
+
+	dstore, err := leveldb.NewDatastore(dir, nil)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return &diskCIDSet{dir: dir, dstore: dstore}, nil
+}
+
+func (d *diskCIDSet) Add(c cid.Cid) (bool, error) {
+	key := dshelp.NewKeyFromBinary(c.Hash())
+
+	has, err := d.dstore.Has(key)
+	if err != nil || has {
+		return false, err
+	}
+
+	return true, d.dstore.Put(key, nil)
+}
+
+func (d *diskCIDSet) Close() error {
+	err := d.dstore.Close()
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(d.dir)
+}
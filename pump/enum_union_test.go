@@ -0,0 +1,58 @@
+package pump
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnionEnumerator(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+	prefV0 := cid.Prefix{Version: 0, Codec: cid.DagProtobuf, MhType: multihash.SHA2_256, MhLength: -1}
+
+	cidList := func(pref cid.Prefix, from, to int) string {
+		var list strings.Builder
+		for i := from; i < to; i++ {
+			c, err := pref.Sum([]byte(fmt.Sprintf("block %d", i)))
+			require.NoError(t, err)
+			list.WriteString(c.String() + "\n")
+		}
+		return list.String()
+	}
+
+	diskSet, err := NewDiskCIDSet()
+	require.NoError(t, err)
+
+	for _, set := range []CIDSet{NewMemoryCIDSet(), diskSet} {
+		first, err := NewFileEnumerator(strings.NewReader(cidList(pref, 0, 30)))
+		require.NoError(t, err)
+		// the same blocks listed as CIDv0 are still duplicates
+		second, err := NewFileEnumerator(strings.NewReader(cidList(prefV0, 20, 50)))
+		require.NoError(t, err)
+
+		union := NewUnionEnumerator(set, first, second)
+		require.Equal(t, 60, union.TotalCount())
+
+		out := make(chan BlockInfo)
+		require.NoError(t, union.CIDs(out))
+
+		seen := make(map[cid.Cid]bool)
+		for info := range out {
+			require.NoError(t, info.Error)
+			require.False(t, seen[info.CID])
+			seen[info.CID] = true
+		}
+		require.Len(t, seen, 50)
+		require.Equal(t, uint64(10), union.Duplicates())
+	}
+
+	// unknown counts are ignored
+	first, err := NewFileEnumerator(strings.NewReader(cidList(pref, 0, 30)))
+	require.NoError(t, err)
+	require.Equal(t, 30, NewUnionEnumerator(NewMemoryCIDSet(), first, &DatastoreEnumerator{}).TotalCount())
+	require.Equal(t, -1, NewUnionEnumerator(NewMemoryCIDSet(), &DatastoreEnumerator{}).TotalCount())
+}
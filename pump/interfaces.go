@@ -5,6 +5,36 @@ import "github.com/ipfs/go-cid"
 type BlockInfo struct {
 	Error error
 	CID   cid.Cid
+
+	// Pin is set when the source know how the block is pinned
+	Pin *PinInfo
+
+	// Size is the expected size of the block, 0 if unknown
+	Size uint64
+
+	// Meta hold the extra fields given by the source, if any
+	Meta map[string]string
+}
+
+// Pin types, as reported by IPFS
+const (
+	PinTypeRecursive = "recursive"
+	PinTypeDirect    = "direct"
+	PinTypeIndirect  = "indirect"
+)
+
+// PinInfo is the optional pin metadata of a block
+type PinInfo struct {
+	// Type is one of PinTypeRecursive, PinTypeDirect or PinTypeIndirect,
+	// or empty if unknown
+	Type string
+	Name string
+	Meta map[string]string
+
+	// IPFS Cluster settings, when the pin comes from a cluster
+	ReplicationMin int
+	ReplicationMax int
+	Allocations    []string
 }
 
 // An Enumerator is able to enumerate the blocks from a source
@@ -21,6 +51,11 @@ type Block struct {
 	Error error
 	CID   cid.Cid
 	Data  []byte
+
+	// Pin, Size and Meta are forwarded from the BlockInfo
+	Pin  *PinInfo
+	Size uint64
+	Meta map[string]string
 }
 
 // A Collector is able to read a block from a source
@@ -35,6 +70,28 @@ type Drain interface {
 	Drain(block Block) error
 }
 
+// A BatchDrain is a Drain that buffer the blocks and write them in batches.
+// As Drain only queue the block, failures are reported asynchronously.
+type BatchDrain interface {
+	Drain
+
+	// OnFailure set the function to call for each block that failed to be written
+	OnFailure(func(c cid.Cid))
+
+	// Flush write the remaining buffered blocks
+	Flush() error
+}
+
+// A ConfirmingDrain is a BatchDrain that also report the blocks once written.
+// Each block queued by Drain is then reported exactly once, through OnFailure
+// or OnSuccess, possibly before Drain returned.
+type ConfirmingDrain interface {
+	BatchDrain
+
+	// OnSuccess set the function to call for each block written successfully
+	OnSuccess(func(c cid.Cid))
+}
+
 type CountedDrain interface {
 	Drain
 	SuccessfulBlocksCount() uint64
@@ -71,12 +71,15 @@ func (m *MockCollector) Blocks(in <-chan BlockInfo, out chan<- Block) error {
 		for info := range in {
 			data, ok := m.source.Load(info.CID.String())
 			if !ok {
-				out <- Block{CID: info.CID, Error: fmt.Errorf("unknown block")}
+				out <- Block{CID: info.CID, Pin: info.Pin, Size: info.Size, Meta: info.Meta, Error: fmt.Errorf("unknown block")}
 				continue
 			}
 
 			out <- Block{
 				CID:  info.CID,
+				Pin:  info.Pin,
+				Size: info.Size,
+				Meta: info.Meta,
 				Data: data.([]byte),
 			}
 		}
@@ -151,3 +154,62 @@ func (m *mockCidPrefDrain) Drain(block Block) error {
 
 	return nil
 }
+
+// mockBatchDrain queue the blocks, and write them by batches of batchSize,
+// failing the ones in Failing
+type mockBatchDrain struct {
+	batchSize int
+	Failing   map[cid.Cid]bool
+
+	mu        sync.Mutex
+	pending   []Block
+	Written   []Block
+	onFailure func(c cid.Cid)
+	onSuccess func(c cid.Cid)
+}
+
+func newMockBatchDrain(batchSize int) *mockBatchDrain {
+	return &mockBatchDrain{batchSize: batchSize, Failing: make(map[cid.Cid]bool)}
+}
+
+func (m *mockBatchDrain) OnFailure(f func(c cid.Cid)) {
+	m.onFailure = f
+}
+
+func (m *mockBatchDrain) OnSuccess(f func(c cid.Cid)) {
+	m.onSuccess = f
+}
+
+func (m *mockBatchDrain) Drain(block Block) error {
+	m.mu.Lock()
+	m.pending = append(m.pending, block)
+	if len(m.pending) < m.batchSize {
+		m.mu.Unlock()
+		return nil
+	}
+	m.mu.Unlock()
+
+	return m.Flush()
+}
+
+func (m *mockBatchDrain) Flush() error {
+	m.mu.Lock()
+	batch := m.pending
+	m.pending = nil
+	for _, block := range batch {
+		if !m.Failing[block.CID] {
+			m.Written = append(m.Written, block)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, block := range batch {
+		switch {
+		case m.Failing[block.CID] && m.onFailure != nil:
+			m.onFailure(block.CID)
+		case !m.Failing[block.CID] && m.onSuccess != nil:
+			m.onSuccess(block.CID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package pump
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// pinningServiceClient is a minimal client for the IPFS Pinning Service API
+// https://ipfs.github.io/pinning-services-api-spec/
+type pinningServiceClient struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+type psPin struct {
+	CID     string            `json:"cid"`
+	Name    string            `json:"name,omitempty"`
+	Origins []string          `json:"origins,omitempty"`
+	Meta    map[string]string `json:"meta,omitempty"`
+}
+
+type psPinStatus struct {
+	RequestID string    `json:"requestid"`
+	Status    string    `json:"status"`
+	Created   time.Time `json:"created"`
+	Pin       psPin     `json:"pin"`
+}
+
+type psPinResults struct {
+	Count   int           `json:"count"`
+	Results []psPinStatus `json:"results"`
+}
+
+type psListQuery struct {
+	CIDs   []string
+	Name   string
+	Status []string
+	Before time.Time
+	Limit  int
+}
+
+func newPinningServiceClient(URL, token string, client *http.Client) *pinningServiceClient {
+	if client == nil {
+		client = &http.Client{Timeout: 1 * time.Minute}
+	}
+
+	return &pinningServiceClient{
+		url:    strings.TrimSuffix(URL, "/"),
+		token:  token,
+		client: client,
+	}
+}
+
+func (p *pinningServiceClient) listPins(ctx context.Context, query psListQuery) (*psPinResults, error) {
+	params := url.Values{}
+	if len(query.CIDs) > 0 {
+		params.Set("cid", strings.Join(query.CIDs, ","))
+	}
+	if query.Name != "" {
+		params.Set("name", query.Name)
+	}
+	if len(query.Status) > 0 {
+		params.Set("status", strings.Join(query.Status, ","))
+	}
+	if !query.Before.IsZero() {
+		params.Set("before", query.Before.Format(time.RFC3339Nano))
+	}
+	if query.Limit > 0 {
+		params.Set("limit", strconv.Itoa(query.Limit))
+	}
+
+	var res psPinResults
+	err := p.do(ctx, http.MethodGet, "/pins?"+params.Encode(), nil, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p *pinningServiceClient) addPin(ctx context.Context, pin psPin) (*psPinStatus, error) {
+	var res psPinStatus
+	err := p.do(ctx, http.MethodPost, "/pins", pin, &res)
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+func (p *pinningServiceClient) do(ctx context.Context, method, path string, body interface{}, res interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, p.url+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	raw, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("pinning service returned %s: %s", resp.Status, strings.TrimSpace(string(raw)))
+	}
+
+	return json.Unmarshal(raw, res)
+}
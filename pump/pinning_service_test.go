@@ -0,0 +1,186 @@
+package pump
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+// mockPinningService is a minimal in-memory Pinning Service API
+type mockPinningService struct {
+	mu    sync.Mutex
+	pins  []psPinStatus
+	token string
+
+	// sameTime is how many consecutive pins share a creation date
+	sameTime int
+}
+
+func (m *mockPinningService) add(pin psPin, status string) psPinStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sameTime := m.sameTime
+	if sameTime < 1 {
+		sameTime = 1
+	}
+
+	ps := psPinStatus{
+		RequestID: strconv.Itoa(len(m.pins)),
+		Status:    status,
+		Created:   time.Unix(1600000000, 0).Add(time.Duration(len(m.pins)/sameTime) * time.Second).UTC(),
+		Pin:       pin,
+	}
+	m.pins = append(m.pins, ps)
+	return ps
+}
+
+func (m *mockPinningService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer "+m.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var pin psPin
+		if err := json.NewDecoder(r.Body).Decode(&pin); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		_ = json.NewEncoder(w).Encode(m.add(pin, "queued"))
+
+	case http.MethodGet:
+		q := r.URL.Query()
+		limit := 10
+		if q.Get("limit") != "" {
+			limit, _ = strconv.Atoi(q.Get("limit"))
+		}
+		var before time.Time
+		if q.Get("before") != "" {
+			before, _ = time.Parse(time.RFC3339Nano, q.Get("before"))
+		}
+
+		m.mu.Lock()
+		var matching []psPinStatus
+		for _, ps := range m.pins {
+			if q.Get("cid") != "" && !contains(strings.Split(q.Get("cid"), ","), ps.Pin.CID) {
+				continue
+			}
+			if q.Get("status") != "" && !contains(strings.Split(q.Get("status"), ","), ps.Status) {
+				continue
+			}
+			if q.Get("name") != "" && q.Get("name") != ps.Pin.Name {
+				continue
+			}
+			matching = append(matching, ps)
+		}
+		m.mu.Unlock()
+
+		sort.Slice(matching, func(i, j int) bool { return matching[i].Created.After(matching[j].Created) })
+
+		res := psPinResults{Count: len(matching)}
+		for _, ps := range matching {
+			if !before.IsZero() && !ps.Created.Before(before) {
+				continue
+			}
+			if len(res.Results) == limit {
+				break
+			}
+			res.Results = append(res.Results, ps)
+		}
+		_ = json.NewEncoder(w).Encode(res)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPinningService(t *testing.T) {
+	source := &mockPinningService{token: "source"}
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+	for i := 0; i < 2500; i++ {
+		c, err := pref.Sum([]byte(strconv.Itoa(i)))
+		require.NoError(t, err)
+		status := "pinned"
+		if i%10 == 0 {
+			status = "failed"
+		}
+		source.add(psPin{CID: c.String(), Name: fmt.Sprintf("pin-%d", i), Meta: map[string]string{"i": strconv.Itoa(i)}}, status)
+	}
+	sourceServer := httptest.NewServer(source)
+	defer sourceServer.Close()
+
+	target := &mockPinningService{token: "target"}
+	targetServer := httptest.NewServer(target)
+	defer targetServer.Close()
+
+	enum := NewPinningServiceEnumerator(sourceServer.URL, "source", []string{"pinned"}, "", nil)
+	drain := NewCountedDrain(NewPinningServiceDrain(targetServer.URL+"/", "target", nil))
+	failed := NewNullableFileEnumeratorWriter()
+
+	PumpIt(enum, NewNoopCollector(), drain, failed, NewNullProgressWriter(), 4)
+
+	require.Equal(t, 2250, enum.TotalCount())
+	require.Equal(t, uint64(2250), drain.SuccessfulBlocksCount())
+	require.Equal(t, uint(0), failed.Count())
+	require.Len(t, target.pins, 2250)
+
+	for _, ps := range target.pins {
+		i, err := strconv.Atoi(ps.Pin.Meta["i"])
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("pin-%d", i), ps.Pin.Name)
+		require.NotZero(t, i%10)
+	}
+
+	// Pumping again doesn't duplicate the pins
+	enum = NewPinningServiceEnumerator(sourceServer.URL, "source", []string{"pinned"}, "pin-42", nil)
+	PumpIt(enum, NewNoopCollector(), drain, failed, NewNullProgressWriter(), 1)
+	require.Equal(t, 1, enum.TotalCount())
+	require.Len(t, target.pins, 2250)
+}
+
+func TestPinningServiceSharedCreationDate(t *testing.T) {
+	// page boundaries fall in the middle of pins created at the same time
+	source := &mockPinningService{token: "source", sameTime: 7}
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+	for i := 0; i < 2500; i++ {
+		c, err := pref.Sum([]byte(strconv.Itoa(i)))
+		require.NoError(t, err)
+		source.add(psPin{CID: c.String()}, "pinned")
+	}
+	sourceServer := httptest.NewServer(source)
+	defer sourceServer.Close()
+
+	enum := NewPinningServiceEnumerator(sourceServer.URL, "source", []string{"pinned"}, "", nil)
+	out := make(chan BlockInfo)
+	require.NoError(t, enum.CIDs(out))
+
+	seen := make(map[cid.Cid]int)
+	for info := range out {
+		require.NoError(t, info.Error)
+		seen[info.CID]++
+	}
+	require.Len(t, seen, 2500)
+	for _, count := range seen {
+		require.Equal(t, 1, count)
+	}
+}
@@ -72,6 +72,14 @@ func PumpIt(enumerator Enumerator, collector Collector, drain Drain, failedBlock
 		close(blocks)
 	}()
 
+	// Batch drains report their failures asynchronously
+	batchDrain, isBatch := drain.(BatchDrain)
+	if isBatch {
+		batchDrain.OnFailure(func(c cid.Cid) {
+			failedBlocks <- c
+		})
+	}
+
 	// Spawn drain workers
 	var wgDrain sync.WaitGroup
 	for i := uint(0); i < worker; i++ {
@@ -113,6 +121,14 @@ func PumpIt(enumerator Enumerator, collector Collector, drain Drain, failedBlock
 	// Close the failed blocks channel when all the drainer worker are done
 	go func() {
 		wgDrain.Wait()
+
+		if isBatch {
+			err := batchDrain.Flush()
+			if err != nil {
+				log.Println(errors.Wrap(err, "failed to flush the drain"))
+			}
+		}
+
 		close(failedBlocks)
 	}()
 
@@ -0,0 +1,160 @@
+package pump
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	ds "github.com/ipfs/go-datastore"
+	dsq "github.com/ipfs/go-datastore/query"
+	"github.com/pkg/errors"
+)
+
+// KeyFilter select datastore entries by key prefix. A key is selected if it is
+// under one of the Include prefixes (or if there is none), and under none of
+// the Exclude prefixes.
+type KeyFilter struct {
+	Include []ds.Key
+	Exclude []ds.Key
+}
+
+func (f KeyFilter) Match(key ds.Key) bool {
+	if len(f.Include) > 0 && !underAny(key, f.Include) {
+		return false
+	}
+	return !underAny(key, f.Exclude)
+}
+
+// outermostKeys return the prefixes that are not under another one
+func outermostKeys(prefixes []ds.Key) []ds.Key {
+	var res []ds.Key
+	for i, prefix := range prefixes {
+		nested := false
+		for j, other := range prefixes {
+			if i == j {
+				continue
+			}
+			// keep the first of the duplicates
+			if other.IsAncestorOf(prefix) || (other.Equal(prefix) && j < i) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			res = append(res, prefix)
+		}
+	}
+	return res
+}
+
+func underAny(key ds.Key, prefixes []ds.Key) bool {
+	for _, prefix := range prefixes {
+		if prefix.Equal(key) || prefix.IsAncestorOf(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// RawPumpIt copy the entries of the src datastore matching the filter into
+// the dst datastore as-is, without interpreting keys as blocks. This allow to
+// migrate the non-block keyspaces of a node (pins, MFS root, IPNS records ...).
+// Failed keys are written in failedKeys, one per line.
+func RawPumpIt(src ds.Datastore, dst ds.Datastore, filter KeyFilter, failedKeys io.Writer, progressWriter ProgressWriter, worker uint) {
+	if worker == 0 {
+		log.Fatal("minimal number of worker is 1")
+	}
+
+	keys := make(chan ds.Key, 500000)
+	failed := make(chan ds.Key)
+
+	// Single worker for the enumeration
+	go func() {
+		defer close(keys)
+
+		// a prefix under another would list its keys twice
+		prefixes := outermostKeys(filter.Include)
+		if len(prefixes) == 0 {
+			prefixes = []ds.Key{ds.NewKey("/")}
+		}
+
+		for _, prefix := range prefixes {
+			err := queryKeys(src, prefix, filter, keys, progressWriter)
+			if err != nil {
+				log.Println(errors.Wrapf(err, "error enumerating keys under %s", prefix))
+			}
+		}
+		progressWriter.Finish()
+	}()
+
+	// Spawn copy workers
+	var wgCopy sync.WaitGroup
+	for i := uint(0); i < worker; i++ {
+		wgCopy.Add(1)
+
+		go func() {
+			for key := range keys {
+				value, err := src.Get(key)
+				if err != nil {
+					log.Println(errors.Wrapf(err, "error retrieving key %s", key))
+					failed <- key
+					continue
+				}
+
+				err = dst.Put(key, value)
+				if err != nil {
+					log.Println(errors.Wrapf(err, "failed to push key %s", key))
+					failed <- key
+					continue
+				}
+			}
+			wgCopy.Done()
+		}()
+	}
+
+	// Close the failed channel when all the copy worker are done
+	go func() {
+		wgCopy.Wait()
+		close(failed)
+	}()
+
+	for key := range failed {
+		_, err := fmt.Fprintln(failedKeys, key.String())
+		if err != nil {
+			log.Println(fmt.Errorf("failed to write failed key %s", key))
+		}
+	}
+
+	err := dst.Sync(ds.NewKey("/"))
+	if err != nil {
+		log.Println(errors.Wrap(err, "failed to sync the destination"))
+	}
+}
+
+func queryKeys(dstore ds.Datastore, prefix ds.Key, filter KeyFilter, out chan<- ds.Key, progressWriter ProgressWriter) error {
+	res, err := dstore.Query(dsq.Query{Prefix: prefix.String(), KeysOnly: true})
+	if err != nil {
+		return err
+	}
+	defer res.Close()
+
+	for {
+		e, ok := res.NextSync()
+		if !ok {
+			return nil
+		}
+		if e.Error != nil {
+			return e.Error
+		}
+
+		key := ds.RawKey(e.Key)
+		if !filter.Match(key) {
+			continue
+		}
+
+		progressWriter.Increment()
+		progressWriter.Prefix(e.Key)
+		out <- key
+	}
+}
@@ -0,0 +1,80 @@
+package pump
+
+import (
+	"bytes"
+	"testing"
+
+	ds "github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRawPumpIt(t *testing.T) {
+	src := ds.NewMapDatastore()
+	for _, key := range []string{
+		"/blocks/CIQA4T3TD3BP3C2M3GXCGRCRTCCHV7XSGAZPZJOAOHLPOI6IQR3H6YQ",
+		"/local/filesroot",
+		"/pins/pinset",
+		"/pins/index/foo",
+		"/pinsx",
+		"/ipns/foo",
+	} {
+		require.NoError(t, src.Put(ds.NewKey(key), []byte(key)))
+	}
+
+	dst := ds.NewMapDatastore()
+	filter := KeyFilter{
+		Include: []ds.Key{ds.NewKey("/pins"), ds.NewKey("/local"), ds.NewKey("/ipns")},
+		Exclude: []ds.Key{ds.NewKey("/pins/index")},
+	}
+
+	var failed bytes.Buffer
+	RawPumpIt(src, dst, filter, &failed, NewNullProgressWriter(), 3)
+	require.Empty(t, failed.String())
+
+	for key, expected := range map[string]bool{
+		"/blocks/CIQA4T3TD3BP3C2M3GXCGRCRTCCHV7XSGAZPZJOAOHLPOI6IQR3H6YQ": false,
+		"/local/filesroot": true,
+		"/pins/pinset":     true,
+		"/pins/index/foo":  false,
+		"/pinsx":           false,
+		"/ipns/foo":        true,
+	} {
+		has, err := dst.Has(ds.NewKey(key))
+		require.NoError(t, err)
+		require.Equal(t, expected, has, key)
+
+		if expected {
+			value, err := dst.Get(ds.NewKey(key))
+			require.NoError(t, err)
+			require.Equal(t, []byte(key), value)
+		}
+	}
+}
+
+// countingProgressWriter count the enumerated keys
+type countingProgressWriter struct {
+	NullProgressWriter
+	count int
+}
+
+func (p *countingProgressWriter) Increment() int {
+	p.count++
+	return p.count
+}
+
+func TestRawPumpItNestedIncludes(t *testing.T) {
+	src := ds.NewMapDatastore()
+	for _, key := range []string{"/pins/pinset", "/pins/index/foo", "/pins/index/bar", "/ipns/foo"} {
+		require.NoError(t, src.Put(ds.NewKey(key), []byte(key)))
+	}
+
+	filter := KeyFilter{
+		Include: []ds.Key{ds.NewKey("/pins/index"), ds.NewKey("/pins"), ds.NewKey("/pins")},
+	}
+
+	progress := &countingProgressWriter{}
+	var failed bytes.Buffer
+	RawPumpIt(src, ds.NewMapDatastore(), filter, &failed, progress, 2)
+	require.Empty(t, failed.String())
+	require.Equal(t, 3, progress.count)
+}
@@ -1,14 +1,18 @@
 package pump
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
 	"github.com/multiformats/go-multihash"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -95,3 +99,53 @@ func testPump(t *testing.T, drain Drain, count int, failedCount uint, worker uin
 		assert.Equal(t, uint64(failedCount), successMockedDrain.Drained)
 	}
 }
+
+// blocksDrain keep the drained blocks
+type blocksDrain struct {
+	mu     sync.Mutex
+	blocks map[cid.Cid]Block
+}
+
+func (b *blocksDrain) Drain(block Block) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.blocks[block.CID] = block
+	return nil
+}
+
+func TestPumpForwardBlockInfo(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+
+	dstore := ds.NewMapDatastore()
+	list := "cid,size,owner\n"
+	for i := 0; i < 10; i++ {
+		data := []byte(fmt.Sprintf("block %d", i))
+		c, err := pref.Sum(data)
+		require.NoError(t, err)
+		require.NoError(t, dstore.Put(dshelp.CidToDsKey(c), data))
+		list += fmt.Sprintf("%s,%d,owner-%d\n", c, len(data), i)
+	}
+
+	fallback, err := NewFallbackCollector(
+		&FallbackSource{Name: "empty", Collector: NewDatastoreCollector(ds.NewMapDatastore())},
+		&FallbackSource{Name: "full", Collector: NewDatastoreCollector(dstore)},
+	)
+	require.NoError(t, err)
+
+	for _, collector := range []Collector{NewDatastoreCollector(dstore), NewNoopCollector(), fallback} {
+		enumerator, err := NewFileEnumeratorWithOptions(strings.NewReader(list), FileOptions{Format: FileFormatCSV, CSVHeader: true})
+		require.NoError(t, err)
+		drain := &blocksDrain{blocks: make(map[cid.Cid]Block)}
+		failed := NewNullableFileEnumeratorWriter()
+
+		PumpIt(enumerator, collector, drain, failed, NewNullProgressWriter(), 4)
+
+		require.Equal(t, uint(0), failed.Count())
+		require.Len(t, drain.blocks, 10)
+		for _, block := range drain.blocks {
+			require.NotZero(t, block.Size)
+			require.Len(t, block.Meta, 1)
+			require.Contains(t, block.Meta["owner"], "owner-")
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package pump
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+)
+
+// Shard is a disjoint share of the blocks, to split a pump across several
+// instances. Index is zero based.
+type Shard struct {
+	Index int
+	Count int
+}
+
+// ParseShard parse a shard as "i/N", with i from 1 to N
+func ParseShard(str string) (Shard, error) {
+	split := strings.Split(str, "/")
+	if len(split) != 2 {
+		return Shard{}, fmt.Errorf("invalid shard %q, expected i/N", str)
+	}
+
+	i, err := strconv.Atoi(split[0])
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard %q, expected i/N", str)
+	}
+	n, err := strconv.Atoi(split[1])
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard %q, expected i/N", str)
+	}
+	if n < 1 || i < 1 || i > n {
+		return Shard{}, fmt.Errorf("invalid shard %q, expected 1 <= i <= N", str)
+	}
+
+	return Shard{Index: i - 1, Count: n}, nil
+}
+
+func (s Shard) String() string {
+	return fmt.Sprintf("%d/%d", s.Index+1, s.Count)
+}
+
+// Match tells if the CID belong to the shard, using the multihash digest
+// modulo the shard count. The digest of a cryptographic hash is uniformly
+// distributed, others (identity ...) are hashed first.
+func (s Shard) Match(c cid.Cid) bool {
+	if s.Count <= 1 {
+		return true
+	}
+
+	digest := c.Hash()
+	if decoded, err := multihash.Decode(c.Hash()); err == nil {
+		digest = decoded.Digest
+		if decoded.Code == multihash.IDENTITY || len(digest) < 8 {
+			sum := sha256.Sum256(digest)
+			digest = sum[:]
+		}
+	}
+
+	v := binary.BigEndian.Uint64(digest[len(digest)-8:])
+	return v%uint64(s.Count) == uint64(s.Index)
+}
+
+// matchIndex tells if the i-th element of a partitioned list belong to the shard
+func (s Shard) matchIndex(i int) bool {
+	return s.Count <= 1 || i%s.Count == s.Index
+}
+
+var _ Enumerator = &ShardEnumerator{}
+
+// ShardEnumerator only emit the CIDs of another enumerator that belong to a
+// shard. The source still enumerate everything, see DatastoreEnumerator.Shard
+// to list only a part of a datastore.
+type ShardEnumerator struct {
+	enumerator Enumerator
+	shard      Shard
+}
+
+func NewShardEnumerator(enumerator Enumerator, shard Shard) *ShardEnumerator {
+	return &ShardEnumerator{enumerator: enumerator, shard: shard}
+}
+
+// TotalCount return the expected share of the source count
+func (s *ShardEnumerator) TotalCount() int {
+	total := s.enumerator.TotalCount()
+	if total < 0 || s.shard.Count <= 1 {
+		return total
+	}
+	return total / s.shard.Count
+}
+
+func (s *ShardEnumerator) CIDs(out chan<- BlockInfo) error {
+	in := make(chan BlockInfo)
+	err := s.enumerator.CIDs(in)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for info := range in {
+			if info.Error == nil && !s.shard.Match(info.CID) {
+				continue
+			}
+			out <- info
+		}
+		close(out)
+	}()
+
+	return nil
+}
@@ -0,0 +1,154 @@
+package pump
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	dshelp "github.com/ipfs/go-ipfs-ds-help"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseShard(t *testing.T) {
+	shard, err := ParseShard("2/4")
+	require.NoError(t, err)
+	require.Equal(t, Shard{Index: 1, Count: 4}, shard)
+	require.Equal(t, "2/4", shard.String())
+
+	for _, invalid := range []string{"", "1", "0/4", "5/4", "a/4", "1/0", "1/2/3"} {
+		_, err := ParseShard(invalid)
+		require.Error(t, err, invalid)
+	}
+}
+
+func TestShardEnumerator(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+	identityPref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.IDENTITY, MhLength: -1}
+
+	var list strings.Builder
+	for i := 0; i < 1000; i++ {
+		p := pref
+		if i%10 == 0 {
+			p = identityPref
+		}
+		c, err := p.Sum([]byte(fmt.Sprintf("block %d", i)))
+		require.NoError(t, err)
+		list.WriteString(c.String() + "\n")
+	}
+
+	seen := make(map[cid.Cid]int)
+	for i := 0; i < 4; i++ {
+		file, err := NewFileEnumerator(strings.NewReader(list.String()))
+		require.NoError(t, err)
+
+		enumerator := NewShardEnumerator(file, Shard{Index: i, Count: 4})
+		require.Equal(t, 250, enumerator.TotalCount())
+
+		out := make(chan BlockInfo)
+		require.NoError(t, enumerator.CIDs(out))
+
+		count := 0
+		for info := range out {
+			require.NoError(t, info.Error)
+			seen[info.CID]++
+			count++
+		}
+		require.InDelta(t, 250, count, 60)
+	}
+
+	// each CID is in exactly one shard
+	require.Len(t, seen, 1000)
+	for _, count := range seen {
+		require.Equal(t, 1, count)
+	}
+}
+
+func TestDatastoreEnumeratorShard(t *testing.T) {
+	_, ok := NewDatastoreEnumerator(nil).Shard(Shard{Index: 0, Count: 2})
+	require.False(t, ok)
+
+	enumerator := NewDatastoreEnumerator(nil)
+	enumerator.Prefixes = []string{"A", "B", "C", "D", "E"}
+	_, err := enumerator.partitions()
+	require.Error(t, err, "prefixes on a datastore matching key segments")
+
+	enumerator.keyPrefixes = true
+
+	first, ok := enumerator.Shard(Shard{Index: 0, Count: 2})
+	require.True(t, ok)
+	partitions, err := first.partitions()
+	require.NoError(t, err)
+	require.Equal(t, []string{"A", "C", "E"}, partitions)
+
+	second, ok := enumerator.Shard(Shard{Index: 1, Count: 2})
+	require.True(t, ok)
+	partitions, err = second.partitions()
+	require.NoError(t, err)
+	require.Equal(t, []string{"B", "D"}, partitions)
+}
+
+func TestDefaultKeyPartitions(t *testing.T) {
+	partitions := defaultKeyPartitions()
+	require.NoError(t, checkOverlappingPrefixes(partitions))
+	// the common prefixes split in 32, and the rest of each level
+	require.Len(t, partitions, 3*32+30+31+30+31+31)
+
+	// every key match exactly one partition
+	for _, pref := range []cid.Prefix{
+		{Version: 0, Codec: cid.DagProtobuf, MhType: multihash.SHA2_256, MhLength: -1},
+		{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1},
+		{Version: 1, Codec: cid.DagCBOR, MhType: multihash.SHA2_256, MhLength: -1},
+		{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_512, MhLength: -1},
+		{Version: 1, Codec: cid.Raw, MhType: multihash.IDENTITY, MhLength: -1},
+	} {
+		for i := 0; i < 100; i++ {
+			c, err := pref.Sum([]byte(strconv.Itoa(i)))
+			require.NoError(t, err)
+
+			for _, key := range []string{dshelp.CidToDsKey(c).String(), dshelp.NewKeyFromBinary(c.Hash()).String()} {
+				matching := 0
+				for _, partition := range partitions {
+					if strings.HasPrefix(key, partition) {
+						matching++
+					}
+				}
+				require.Equal(t, 1, matching, key)
+			}
+		}
+	}
+
+	enumerator := NewDatastoreEnumerator(nil)
+	enumerator.keyPrefixes = true
+	res, err := enumerator.partitions()
+	require.NoError(t, err)
+	require.Equal(t, []string{""}, res, "a single query without parallelism")
+
+	sharded, ok := enumerator.Shard(Shard{Index: 1, Count: 4})
+	require.True(t, ok)
+	res, err = sharded.partitions()
+	require.NoError(t, err)
+	require.Len(t, res, len(partitions)/4)
+
+	// the total is extrapolated from the partitions already listed
+	atomic.StoreInt64(&sharded.partitionsCount, int64(len(res)))
+	atomic.StoreInt64(&sharded.partitionsDone, 2)
+	atomic.StoreInt64(&sharded.emitted, 100)
+	require.Equal(t, 100*len(res)/2, sharded.TotalCount())
+}
+
+func TestOverlappingPrefixes(t *testing.T) {
+	require.NoError(t, checkOverlappingPrefixes([]string{"CIQA", "CIQB", "/AFK"}))
+	require.Error(t, checkOverlappingPrefixes([]string{"CIQA", "CIQ"}))
+	require.Error(t, checkOverlappingPrefixes([]string{"CIQA", "/CIQAB"}))
+	require.Error(t, checkOverlappingPrefixes([]string{"CIQA", "CIQB", "CIQA"}))
+
+	enumerator := NewDatastoreEnumerator(nil)
+	enumerator.keyPrefixes = true
+	enumerator.Prefixes = []string{"CIQ", "CIQA"}
+	_, err := enumerator.partitions()
+	require.Error(t, err)
+}
@@ -0,0 +1,270 @@
+package pump
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+
+	"github.com/ipfs/go-cid"
+	"github.com/pkg/errors"
+)
+
+// VerifyReport is the result of VerifyIt
+type VerifyReport struct {
+	// Checked is the number of blocks enumerated
+	Checked uint64
+	// Valid blocks are present on the destination and match their CID
+	Valid uint64
+	// Missing blocks couldn't be retrieved from the destination
+	Missing uint64
+	// Corrupt blocks don't match their CID on the destination
+	Corrupt uint64
+	// Different blocks differ from the source, when comparing the bytes
+	Different uint64
+	// SourceFailed blocks couldn't be retrieved from the source for the byte
+	// comparison. They are still counted as valid.
+	SourceFailed uint64
+
+	// Population is the number of blocks the checked ones were sampled from,
+	// or zero if all the blocks were checked.
+	Population uint64
+}
+
+// sampleConfidenceZ is the z-score of the 95% confidence interval
+const sampleConfidenceZ = 1.96
+
+// Completeness estimate the ratio of valid blocks on the destination, with the
+// bounds of its 95% confidence interval (Wilson score interval). This is only
+// meaningful when the checked blocks are a random sample.
+func (r *VerifyReport) Completeness() (estimate, low, high float64) {
+	if r.Checked == 0 {
+		return 0, 0, 1
+	}
+
+	n := float64(r.Checked)
+	p := float64(r.Checked-r.Bad()) / n
+	z2 := sampleConfidenceZ * sampleConfidenceZ
+
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	margin := sampleConfidenceZ / (1 + z2/n) * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+
+	return p, math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+// Bad return the number of blocks missing or corrupt on the destination
+func (r *VerifyReport) Bad() uint64 {
+	return r.Missing + r.Corrupt + r.Different
+}
+
+// WriteReport write a human readable version of the report
+func (r *VerifyReport) WriteReport(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(tw, "Checked\t%d\t\n", r.Checked)
+	fmt.Fprintf(tw, "Valid\t%d\t\n", r.Valid)
+	fmt.Fprintf(tw, "Missing\t%d\t\n", r.Missing)
+	fmt.Fprintf(tw, "Corrupt\t%d\t\n", r.Corrupt)
+	fmt.Fprintf(tw, "Different from source\t%d\t\n", r.Different)
+	fmt.Fprintf(tw, "Unavailable on source\t%d\t\n", r.SourceFailed)
+
+	if r.Population > 0 {
+		estimate, low, high := r.Completeness()
+		fmt.Fprintf(tw, "\nSampled from\t%d\t\n", r.Population)
+		fmt.Fprintf(tw, "Estimated completeness\t%.3f%%\t(95%% confidence: %.3f%% - %.3f%%)\n",
+			estimate*100, low*100, high*100)
+		fmt.Fprintf(tw, "Estimated bad blocks\t%.0f\t(95%% confidence: %.0f - %.0f)\n",
+			(1-estimate)*float64(r.Population), (1-high)*float64(r.Population), (1-low)*float64(r.Population))
+	}
+
+	return tw.Flush()
+}
+
+// VerifyIt check that every enumerated block is present and intact on the
+// destination. If source is not nil, the bytes are also compared with the
+// source. The missing or corrupt blocks are written to badBlocksWriter, in a
+// format that FileEnumerator can read back.
+func VerifyIt(enumerator Enumerator, source Collector, destination Collector, badBlocksWriter FailedBlocksWriter, progressWriter ProgressWriter, worker uint) *VerifyReport {
+	if worker == 0 {
+		log.Fatal("minimal number of worker is 1")
+	}
+
+	report := &VerifyReport{}
+
+	infoIn := make(chan BlockInfo, 500000)
+	infoOut := make(chan BlockInfo)
+	badBlocks := make(chan cid.Cid)
+
+	err := enumerator.CIDs(infoIn)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// relay to the destination collector workers
+	go func() {
+		for info := range infoIn {
+			progressWriter.Increment()
+			progressWriter.SetTotal(enumerator.TotalCount())
+
+			if info.Error != nil {
+				log.Println(errors.Wrapf(info.Error, "error enumerating block"))
+				continue
+			}
+
+			atomic.AddUint64(&report.Checked, 1)
+			progressWriter.Prefix(info.CID.String())
+			infoOut <- info
+		}
+		progressWriter.Finish()
+		close(infoOut)
+	}()
+
+	// blocks valid on the destination, waiting for the comparison with the source
+	pending := newPendingBlocks()
+	compareIn := make(chan BlockInfo)
+
+	bad := func(c cid.Cid, counter *uint64, reason string) {
+		log.Printf("%s: %s", c, reason)
+		atomic.AddUint64(counter, 1)
+		badBlocks <- c
+	}
+
+	var wgDestination sync.WaitGroup
+	for i := uint(0); i < worker; i++ {
+		wgDestination.Add(1)
+
+		go func() {
+			out := make(chan Block)
+			err := destination.Blocks(infoOut, out)
+			if err != nil {
+				log.Fatal(err)
+			}
+
+			for block := range out {
+				if block.Error != nil {
+					bad(block.CID, &report.Missing, errors.Wrap(block.Error, "missing").Error())
+					continue
+				}
+
+				actual, err := block.CID.Prefix().Sum(block.Data)
+				if err != nil || !actual.Equals(block.CID) {
+					bad(block.CID, &report.Corrupt, "corrupt")
+					continue
+				}
+
+				if source == nil {
+					atomic.AddUint64(&report.Valid, 1)
+					continue
+				}
+
+				pending.push(block.CID, block.Data)
+				compareIn <- BlockInfo{CID: block.CID, Pin: block.Pin, Size: block.Size, Meta: block.Meta}
+			}
+			wgDestination.Done()
+		}()
+	}
+
+	go func() {
+		wgDestination.Wait()
+		close(compareIn)
+	}()
+
+	var wgSource sync.WaitGroup
+	if source != nil {
+		for i := uint(0); i < worker; i++ {
+			wgSource.Add(1)
+
+			go func() {
+				out := make(chan Block)
+				err := source.Blocks(compareIn, out)
+				if err != nil {
+					log.Fatal(err)
+				}
+
+				for block := range out {
+					expected := pending.pop(block.CID)
+
+					if block.Error != nil {
+						log.Println(errors.Wrapf(block.Error, "%s: can't compare, unavailable on the source", block.CID))
+						atomic.AddUint64(&report.SourceFailed, 1)
+						atomic.AddUint64(&report.Valid, 1)
+						continue
+					}
+
+					if !bytes.Equal(block.Data, expected) {
+						bad(block.CID, &report.Different, "different from the source")
+						continue
+					}
+
+					atomic.AddUint64(&report.Valid, 1)
+				}
+				wgSource.Done()
+			}()
+		}
+	}
+
+	// Spawn 1 bad blocks writer worker
+	var wgBadBlocks sync.WaitGroup
+	wgBadBlocks.Add(1)
+
+	go func() {
+		for c := range badBlocks {
+			_, err := badBlocksWriter.Write(c)
+			if err != nil {
+				log.Println(fmt.Errorf("failed to write bad block %s", c.String()))
+			}
+		}
+		wgBadBlocks.Done()
+	}()
+
+	go func() {
+		wgDestination.Wait()
+		wgSource.Wait()
+		close(badBlocks)
+	}()
+
+	wgBadBlocks.Wait()
+	err = badBlocksWriter.Flush()
+	if err != nil {
+		log.Println(fmt.Errorf("failed to flush writing of bad blocks. %v", err))
+	}
+
+	return report
+}
+
+// pendingBlocks hold the destination data of the blocks being compared. A CID
+// can be enumerated more than once, hence the stack per CID.
+type pendingBlocks struct {
+	mu     sync.Mutex
+	blocks map[string][][]byte
+}
+
+func newPendingBlocks() *pendingBlocks {
+	return &pendingBlocks{blocks: make(map[string][][]byte)}
+}
+
+func (p *pendingBlocks) push(c cid.Cid, data []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.blocks[c.KeyString()] = append(p.blocks[c.KeyString()], data)
+}
+
+func (p *pendingBlocks) pop(c cid.Cid) []byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stack := p.blocks[c.KeyString()]
+	if len(stack) == 0 {
+		return nil
+	}
+	data := stack[len(stack)-1]
+	if len(stack) == 1 {
+		delete(p.blocks, c.KeyString())
+	} else {
+		p.blocks[c.KeyString()] = stack[:len(stack)-1]
+	}
+	return data
+}
@@ -0,0 +1,72 @@
+package pump
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyIt(t *testing.T) {
+	pref := cid.Prefix{Version: 1, Codec: cid.Raw, MhType: multihash.SHA2_256, MhLength: -1}
+
+	// 5 blocks missing and 3 corrupt on the destination, 2 different on the source
+	source, destination := &sync.Map{}, &sync.Map{}
+	var list strings.Builder
+	for i := 0; i < 30; i++ {
+		data := []byte(fmt.Sprintf("block %d", i))
+		c, err := pref.Sum(data)
+		require.NoError(t, err)
+		list.WriteString(c.String() + "\n")
+
+		switch {
+		case i < 5:
+			source.Store(c.String(), data)
+		case i < 8:
+			source.Store(c.String(), data)
+			destination.Store(c.String(), []byte("garbage"))
+		case i < 10:
+			source.Store(c.String(), []byte("garbage"))
+			destination.Store(c.String(), data)
+		default:
+			source.Store(c.String(), data)
+			destination.Store(c.String(), data)
+		}
+	}
+
+	run := func(source Collector) (*VerifyReport, uint) {
+		enumerator, err := NewFileEnumerator(strings.NewReader(list.String()))
+		require.NoError(t, err)
+
+		bad := NewNullableFileEnumeratorWriter()
+		report := VerifyIt(enumerator, source, NewMockCollector(destination), bad, NewNullProgressWriter(), 3)
+		return report, bad.Count()
+	}
+
+	report, bad := run(nil)
+	require.Equal(t, VerifyReport{Checked: 30, Valid: 22, Missing: 5, Corrupt: 3}, *report)
+	require.Equal(t, uint(8), bad)
+
+	report, bad = run(NewMockCollector(source))
+	require.Equal(t, VerifyReport{Checked: 30, Valid: 20, Missing: 5, Corrupt: 3, Different: 2}, *report)
+	require.Equal(t, uint(10), bad)
+	require.Equal(t, uint64(10), report.Bad())
+}
+
+func TestVerifyReportCompleteness(t *testing.T) {
+	report := VerifyReport{Checked: 100, Valid: 100, Population: 10000}
+	estimate, low, high := report.Completeness()
+	require.Equal(t, 1.0, estimate)
+	require.InDelta(t, 0.963, low, 0.001)
+	require.InDelta(t, 1.0, high, 1e-9)
+
+	report = VerifyReport{Checked: 1000, Valid: 990, Missing: 10, Population: 1000000}
+	estimate, low, high = report.Completeness()
+	require.Equal(t, 0.99, estimate)
+	require.True(t, low < 0.99 && low > 0.98)
+	require.True(t, high > 0.99 && high < 0.996)
+}
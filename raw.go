@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/INFURA/ipfs-pump/pump"
+	ds "github.com/ipfs/go-datastore"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	rawCmd = kingpin.Command("raw", "Copy raw datastore entries (pins, MFS root, keystore, IPNS records ...) "+
+		"without interpreting the keys as blocks.")
+
+	rawValues  = []string{EnumFlatFS, EnumBadger, EnumS3, EnumLevelDB, EnumPebble, EnumRepo}
+	rawEnumArg = rawCmd.Arg("enum", "The source datastore. "+
+		"Possible values are ["+strings.Join(rawValues, ",")+"].").
+		Required().Enum(rawValues...)
+	rawDrainArg = rawCmd.Arg("drain", "The destination datastore. "+
+		"Possible values are ["+strings.Join(rawValues, ",")+"].").
+		Required().Enum(rawValues...)
+
+	rawInclude = rawCmd.Flag("include", "Only copy the keys under this prefix, can be repeated").Strings()
+	rawExclude = rawCmd.Flag("exclude", "Don't copy the keys under this prefix, can be repeated").Strings()
+)
+
+func runRaw() {
+	defer closeAll()
+
+	src, err := buildRawSource(*rawEnumArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dst, err := buildRawDestination(*rawDrainArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	filter := pump.KeyFilter{
+		Include: toKeys(*rawInclude),
+		Exclude: toKeys(*rawExclude),
+	}
+
+	var failedKeys io.Writer = ioutil.Discard
+	if *failedBlocksPath != "" {
+		file, err := os.OpenFile(*failedBlocksPath, os.O_TRUNC|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := bufio.NewWriter(file)
+		failedKeys = w
+
+		defer func() {
+			err = w.Flush()
+			if err == nil {
+				err = file.Close()
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	pump.RawPumpIt(src, dst, filter, failedKeys, pump.NewProgressWriter(), *worker)
+}
+
+// buildRawSource open the source datastore using the enumerator flags, it's
+// closed on exit
+func buildRawSource(kind string) (ds.Datastore, error) {
+	var src ds.Datastore
+
+	if kind == EnumRepo {
+		requiredFlag(enumRepoPath, *enumRepoPathVal)
+		var err error
+		src, err = pump.OpenRepoDatastore(*enumRepoPathVal)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		enumerator, err := buildEnumerator(kind)
+		if err != nil {
+			return nil, err
+		}
+
+		dsEnumerator, ok := enumerator.(*pump.DatastoreEnumerator)
+		if !ok {
+			return nil, fmt.Errorf("enumerator %s is not a datastore", kind)
+		}
+		src = dsEnumerator.Datastore()
+	}
+
+	closers = append(closers, src.Close)
+	return src, nil
+}
+
+// buildRawDestination open the destination datastore using the drain flags,
+// it's closed on exit
+func buildRawDestination(kind string) (ds.Datastore, error) {
+	if kind == DrainRepo {
+		requiredFlag(drainRepoPath, *drainRepoPathVal)
+		dst, err := pump.OpenRepoDatastore(*drainRepoPathVal)
+		if err != nil {
+			return nil, err
+		}
+		closers = append(closers, dst.Close)
+		return dst, nil
+	}
+
+	drain, err := buildDrain(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	dsDrain, ok := drain.(*pump.DatastoreDrain)
+	if !ok {
+		return nil, fmt.Errorf("drain %s is not a datastore", kind)
+	}
+
+	switch kind {
+	case DrainLevelDB, DrainPebble:
+		// already closed on exit by buildDrain
+	default:
+		closers = append(closers, dsDrain.Datastore().Close)
+	}
+	return dsDrain.Datastore(), nil
+}
+
+func toKeys(prefixes []string) []ds.Key {
+	keys := make([]ds.Key, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		keys = append(keys, ds.NewKey(prefix))
+	}
+	return keys
+}
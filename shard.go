@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+
+	"github.com/INFURA/ipfs-pump/pump"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	shardFlag    = kingpin.Flag("shard", "Only handle the i-th of N disjoint shares of the blocks, as 'i/N', to split a pump across several instances").String()
+	enumPrefix   = kingpin.Flag("enum-prefix", "S3 enumerator: Only list the keys starting with this prefix, can be repeated. With --shard, the prefixes are split between the shards").Strings()
+	enumParallel = kingpin.Flag("enum-parallel", "Datastore enumerators: Number of prefixes, or FlatFS directories, listed concurrently").Default("1").Int()
+	enumPreCount = kingpin.Flag("enum-precount", "Datastore enumerators: Count the keys with a second listing, to get an exact total early").Bool()
+	shard        pump.Shard
+	shardActive  bool
+
+	shardSuffixRegexp = regexp.MustCompile(`\.shard-\d+-of-\d+$`)
+)
+
+// setupShard parse the shard flag, and make the output files specific to
+// the shard so that several instances can share a directory.
+func setupShard() {
+	if *shardFlag == "" {
+		return
+	}
+
+	var err error
+	shard, err = pump.ParseShard(*shardFlag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	shardActive = true
+
+	if *failedBlocksPath != "" {
+		*failedBlocksPath = shardPath(*failedBlocksPath)
+	}
+}
+
+// shardPath return the path of an output file specific to the shard
+func shardPath(path string) string {
+	if !shardActive {
+		return path
+	}
+	return path + shardSuffix(shard)
+}
+
+func shardSuffix(shard pump.Shard) string {
+	return fmt.Sprintf(".shard-%d-of-%d", shard.Index+1, shard.Count)
+}
+
+// shardEnumerator apply the prefixes, the listing options and the shard to
+// the enumerator. A S3 or FlatFS datastore enumerator only list the partitions
+// of its shard, any other enumerator list everything and keep the CIDs of its
+// shard. The failed blocks file of the shard is kept whole, as the partitions
+// don't match the CIDs assigned to the shard otherwise.
+func shardEnumerator(enumerator pump.Enumerator) (pump.Enumerator, error) {
+	dsEnumerator, isDatastore := enumerator.(*pump.DatastoreEnumerator)
+
+	if len(*enumPrefix) > 0 {
+		if !isDatastore {
+			return nil, fmt.Errorf("--enum-prefix is only supported by the S3 enumerator")
+		}
+		dsEnumerator.Prefixes = *enumPrefix
+	}
+	if isDatastore {
+		dsEnumerator.Parallel = *enumParallel
+		dsEnumerator.PreCount = *enumPreCount
+	}
+
+	if !shardActive {
+		return enumerator, nil
+	}
+
+	if isDatastore {
+		if sharded, ok := dsEnumerator.Shard(shard); ok {
+			return sharded, nil
+		}
+	}
+
+	if _, isFile := enumerator.(*pump.FileEnumerator); isFile {
+		switch suffix := shardSuffixRegexp.FindString(*enumFilePathVal); {
+		case suffix == shardSuffix(shard):
+			return enumerator, nil
+		case suffix != "":
+			return nil, fmt.Errorf("%s holds the failed blocks of another shard than %s", *enumFilePathVal, shard)
+		}
+	}
+
+	return pump.NewShardEnumerator(enumerator, shard), nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/INFURA/ipfs-pump/pump"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	transcodeMh      = kingpin.Flag("transcode-mh", "Re-hash the blocks with this multihash type (sha2-512, blake2b-256, blake3 ...) as CIDv1 before draining them, rewriting the dag-pb and dag-cbor links").String()
+	transcodeMapping = kingpin.Flag("transcode-mapping", "Write the old and new CID of each transcoded block to this file").String()
+)
+
+// transcodeDrain wrap the drain with the transcoding, if any
+func transcodeDrain(drain pump.Drain) (pump.Drain, error) {
+	if *transcodeMh == "" {
+		if *transcodeMapping != "" {
+			return nil, fmt.Errorf("--transcode-mapping requires --transcode-mh")
+		}
+		return drain, nil
+	}
+
+	// A parent and its children can fall in different shards, the parent
+	// would then wait forever for children transcoded by another instance.
+	if shardActive {
+		return nil, fmt.Errorf("--transcode-mh can't be used with --shard")
+	}
+
+	mhType, err := pump.ParseMultihashType(*transcodeMh)
+	if err != nil {
+		return nil, err
+	}
+
+	var mapping io.Writer
+	if *transcodeMapping != "" {
+		file, err := os.Create(shardPath(*transcodeMapping))
+		if err != nil {
+			return nil, err
+		}
+		closers = append(closers, file.Close)
+		mapping = file
+	}
+
+	return pump.NewTranscodeDrain(drain, mhType, mapping)
+}
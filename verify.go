@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/INFURA/ipfs-pump/pump"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	verifyCmd = kingpin.Command("verify", "Check that the blocks of a source are present and intact on a destination.")
+
+	verifyEnumArg = verifyCmd.Arg("enum", "The source to enumerate the blocks from. "+
+		"Possible values are ["+strings.Join(enumValues, ",")+"].").
+		Required().Enum(enumValues...)
+	verifyValues   = []string{DrainAPI, DrainDagImport, DrainFlatFS, DrainBadger, DrainS3, DrainLevelDB, DrainPebble, DrainRepo}
+	verifyDrainArg = verifyCmd.Arg("drain", "The destination to verify, configured with the drain flags. "+
+		"Possible values are ["+strings.Join(verifyValues, ",")+"].").
+		Required().Enum(verifyValues...)
+
+	verifyCollArg = verifyCmd.Arg("coll", "The source to compare the bytes with, required with --compare only. "+
+		"Possible values are ["+strings.Join(collValues, ",")+"].").
+		Enum(collValues...)
+
+	verifyCompare = verifyCmd.Flag("compare", "Also compare the bytes with the source").Bool()
+	verifyReport  = verifyCmd.Flag("report", "Path of the report (default: stdout)").String()
+
+	verifySampleCount   = verifyCmd.Flag("sample-count", "Only check a random sample of this many blocks").Int()
+	verifySamplePercent = verifyCmd.Flag("sample-percent", "Only check a random sample of this percentage of the blocks").Float64()
+	verifySampleSeed    = verifyCmd.Flag("sample-seed", "Seed of the random sample, the same seed select the same blocks").Default("1").Uint64()
+)
+
+// runVerify return false if some blocks are missing or corrupt
+func runVerify() bool {
+	enumerator, err := buildEnumerator(*verifyEnumArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enumerator, err = shardEnumerator(enumerator)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	enumerator, err = filterEnumerator(enumerator)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var sampler *pump.SampleEnumerator
+	switch {
+	case *verifySampleCount > 0 && *verifySamplePercent > 0:
+		log.Fatal("--sample-count and --sample-percent are mutually exclusive")
+	case *verifySampleCount > 0:
+		sampler = pump.NewSampleEnumerator(enumerator, *verifySampleCount, *verifySampleSeed)
+	case *verifySamplePercent > 0:
+		sampler, err = pump.NewPercentSampleEnumerator(enumerator, *verifySamplePercent, *verifySampleSeed)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	if sampler != nil {
+		enumerator = sampler
+	}
+
+	var source pump.Collector
+	if *verifyCompare {
+		if *verifyCollArg == "" {
+			log.Fatal("--compare requires a collector to compare the bytes with")
+		}
+		source, err = buildCollector(*verifyCollArg)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	destination, err := buildVerifyDestination(*verifyDrainArg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// missing or corrupt blocks, to be fed back to the file enumerator
+	var badBlocksWriter pump.FailedBlocksWriter
+	if *failedBlocksPath == "" {
+		badBlocksWriter = pump.NewNullableFileEnumeratorWriter()
+	} else {
+		enumWriter, closeWriter, err := pump.NewFileEnumeratorWriter(*failedBlocksPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		badBlocksWriter = enumWriter
+
+		defer func() {
+			err = closeWriter()
+			if err != nil {
+				log.Fatal(err)
+			}
+		}()
+	}
+
+	report := pump.VerifyIt(enumerator, source, destination, badBlocksWriter, pump.NewProgressWriter(), *worker)
+	if sampler != nil {
+		report.Population = sampler.Population()
+	}
+
+	err = writeReport(*verifyReport, report.WriteReport)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return report.Bad() == 0
+}
+
+// buildVerifyDestination build a collector reading from the destination of a drain
+func buildVerifyDestination(kind string) (pump.Collector, error) {
+	// the node must not fetch from the network the blocks it doesn't have
+	switch kind {
+	case DrainAPI:
+		requiredFlag(drainAPIURL, *drainAPIURLVal)
+		collector := pump.NewAPICollectorWithClient(*drainAPIURLVal, apiClient())
+		collector.Offline = true
+		return collector, nil
+	case DrainDagImport:
+		requiredFlag(drainDagImportURL, *drainDagImportURLVal)
+		collector := pump.NewAPICollectorWithClient(*drainDagImportURLVal, apiClient())
+		collector.Offline = true
+		return collector, nil
+	}
+
+	drain, err := buildDrain(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	dsDrain, ok := drain.(*pump.DatastoreDrain)
+	if !ok {
+		return nil, fmt.Errorf("drain %s can't be verified", kind)
+	}
+
+	return pump.NewDatastoreCollector(dsDrain.Datastore()), nil
+}